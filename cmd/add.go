@@ -51,6 +51,7 @@ geneos add netprobe infraprobe12 -S -l`,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "false",
+		"dryrun":   "true",
 	},
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, _ []string) error {
@@ -69,6 +70,7 @@ func init() {
 	addCmd.Flags().Uint16VarP(&addCmdPort, "port", "p", 0, "override the default port selection")
 
 	addCmd.Flags().VarP(&addCmdExtras.Envs, "env", "e", "(all components) Add an environment variable in the format NAME=VALUE")
+	addCmd.Flags().VarP(&addCmdExtras.Labels, "label", "L", "(all components) Add a label in the format NAME=VALUE")
 	addCmd.Flags().VarP(&addCmdExtras.Includes, "include", "i", "(gateways) Add an include file in the format PRIORITY:PATH")
 	addCmd.Flags().VarP(&addCmdExtras.Gateways, "gateway", "g", "(sans) Add a gateway in the format NAME:PORT")
 	addCmd.Flags().VarP(&addCmdExtras.Attributes, "attribute", "a", "(sans) Add an attribute in the format NAME=VALUE")
@@ -89,6 +91,7 @@ var addCmdExtras = instance.ExtraConfigValues{
 	Envs:       instance.StringSliceValues{},
 	Variables:  instance.VarValues{},
 	Types:      instance.StringSliceValues{},
+	Labels:     instance.LabelValues{},
 }
 
 // Add an instance
@@ -112,6 +115,10 @@ func commandAdd(ct *geneos.Component, extras instance.ExtraConfigValues, args []
 		u, _ := user.Current()
 		username = u.Username
 	}
+	// instances on hosts that run them as another account default to it
+	if u := rem.SudoUser(); u != "" {
+		username = u
+	}
 
 	c, err := instance.Get(ct, name)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
@@ -124,7 +131,13 @@ func commandAdd(ct *geneos.Component, extras instance.ExtraConfigValues, args []
 		return
 	}
 
+	// hold the port lock until the new port is saved
+	unlock, err := instance.LockPorts(c.Host())
+	if err != nil {
+		return
+	}
 	if err = c.Add(username, addCmdTemplate, addCmdPort); err != nil {
+		unlock()
 		logError.Fatalln(err)
 	}
 
@@ -132,18 +145,27 @@ func commandAdd(ct *geneos.Component, extras instance.ExtraConfigValues, args []
 		c.V().Set("version", addCmdBase)
 	}
 	instance.SetExtendedValues(c, extras)
-	if err = instance.WriteConfig(c); err != nil {
+	err = instance.WriteConfig(c)
+	if err == nil {
+		instance.Rebuild(c, true)
+	}
+	unlock()
+	if err != nil {
 		return
 	}
-	c.Rebuild(true)
 
 	// reload config as instance data is not updated by Add() as an interface value
-	c.Unload()
-	c.Load()
-	log.Printf("%s added, port %d\n", c, c.V().GetInt("port"))
+	if host.DryRun {
+		log.Printf("%s would be added, port %d\n", c, c.V().GetInt("port"))
+	} else {
+		c.Unload()
+		c.Load()
+		log.Printf("%s added, port %d\n", c, c.V().GetInt("port"))
+	}
 
 	if addCmdStart || addCmdLogs {
-		if err = instance.Start(c); err != nil {
+		results := instance.Results{instance.Start(c)}
+		if err = outputResults(results, results.Err()); err != nil {
 			return
 		}
 		if addCmdLogs {
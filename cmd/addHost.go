@@ -24,19 +24,47 @@ package cmd
 import (
 	"fmt"
 	"net/url"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
 	"wonderland.org/geneos/internal/geneos"
 	"wonderland.org/geneos/internal/host"
 )
 
 // addHostCmd represents the addHost command
 var addHostCmd = &cobra.Command{
-	Use:                   "host [-I] [NAME] [SSHURL]",
-	Aliases:               []string{"remote"},
-	Short:                 "Add a remote host",
-	Long:                  `Add a remote host for integration with other commands.`,
+	Use:     "host [-I] [-J JUMPHOST[,JUMPHOST...]] [-i FILE...] [--passphrasefile FILE] [-p | --passwordfile FILE] [--accept-new] [--sudo] [--sudouser USER] [NAME] [SSHURL]",
+	Aliases: []string{"remote"},
+	Short:   "Add a remote host",
+	Long: `Add a remote host for integration with other commands.
+
+If the host is only reachable through one or more jump hosts (bastions)
+then give them, in the order they are to be traversed, as a comma
+separated list of [USER@]HOST[:PORT] either with the -J flag or in a
+proxyjump parameter of the SSH URL, e.g.
+ssh://geneos@prod1/opt/geneos?proxyjump=admin@bastion:2222
+
+Private keys are taken from an ssh-agent, the files given with -i and
+otherwise the default id_* files in your .ssh directory. Passphrase
+protected keys are decrypted using the passphrase in the file given by
+--passphrasefile or by asking for it once. To also allow password and
+keyboard-interactive authentication use -p, to be prompted for the
+password, or --passwordfile.
+
+The SSH host keys of the host and any jump hosts are checked before the
+host is added. The fingerprints of keys that are not already known are
+shown and you are asked to accept them, unless --accept-new is given.
+Accepted keys are recorded in the geneos-known_hosts file in your user
+configuration directory. See 'geneos host keys' to manage them later.
+
+Instances that run as a user other than the login user are started,
+stopped and signalled through "sudo -n -u USER", which must not ask for
+a password. Allow this for all instances on the host with --sudo, or
+for single instances with their "sudo" setting. With --sudouser USER
+file operations on the host are also done as USER, by running
+sftp-server through sudo, and new instances run as USER by default.`,
 	SilenceUsage:          true,
 	DisableFlagsInUseLine: true,
 	Args:                  cobra.RangeArgs(1, 2),
@@ -44,7 +72,17 @@ var addHostCmd = &cobra.Command{
 		"wildcard": "false",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		_, args := cmdArgs(cmd)
+		_, args, params := cmdArgsParams(cmd)
+		// an SSH URL with query parameters contains an '=' and so
+		// ends up in params
+		for _, p := range params {
+			if strings.HasPrefix(p, "ssh:") {
+				args = append(args, p)
+			}
+		}
+		if len(args) == 0 {
+			return geneos.ErrInvalidArgs
+		}
 
 		var h *host.Host
 		sshurl, err := url.Parse(args[0])
@@ -73,10 +111,25 @@ func init() {
 	addCmd.AddCommand(addHostCmd)
 
 	addHostCmd.Flags().BoolVarP(&addHostCmdInit, "init", "I", false, "Initialise the remote host directories and component files")
+	addHostCmd.Flags().StringVarP(&addHostCmdProxyJump, "proxyjump", "J", "", "Connect through these jump hosts, a comma separated list of [USER@]HOST[:PORT]")
+	addHostCmd.Flags().BoolVar(&addHostCmdAcceptNew, "accept-new", false, "Accept and record host keys that are not already known without asking")
+	addHostCmd.Flags().StringSliceVarP(&addHostCmdIdentityFiles, "identityfile", "i", []string{}, "Private key `FILE` to authenticate with, can be repeated")
+	addHostCmd.Flags().StringVar(&addHostCmdPassphraseFile, "passphrasefile", "", "Read the passphrase for private keys from `FILE`")
+	addHostCmd.Flags().BoolVarP(&addHostCmdPassword, "password", "p", false, "Allow password authentication, prompting for the password")
+	addHostCmd.Flags().StringVar(&addHostCmdPasswordFile, "passwordfile", "", "Allow password authentication, reading the password from `FILE`")
+	addHostCmd.Flags().BoolVar(&addHostCmdSudo, "sudo", false, "Allow instances to run as users other than the login user through sudo")
+	addHostCmd.Flags().StringVar(&addHostCmdSudoUser, "sudouser", "", "Run instances and file operations as `USER` through sudo")
+	addHostCmd.Flags().StringVar(&addHostCmdSFTPServer, "sftpserver", "", "Path to the remote sftp-server `PROGRAM` for use with --sudouser")
 	addHostCmd.Flags().SortFlags = false
 }
 
 var addHostCmdInit bool
+var addHostCmdProxyJump string
+var addHostCmdIdentityFiles []string
+var addHostCmdPassphraseFile, addHostCmdPasswordFile string
+var addHostCmdPassword, addHostCmdAcceptNew bool
+var addHostCmdSudo bool
+var addHostCmdSudoUser, addHostCmdSFTPServer string
 
 func addHost(h *host.Host, sshurl *url.URL) (err error) {
 	if h.Exists() {
@@ -91,17 +144,25 @@ func addHost(h *host.Host, sshurl *url.URL) (err error) {
 		return fmt.Errorf("unsupported scheme (ssh only at the moment): %q", sshurl.Scheme)
 	}
 
-	h.SetDefault("hostname", sshurl.Hostname())
+	// the hostname in the URL, or the name of the host, is looked up
+	// in the user's ssh config, as ssh(1) would, and any settings
+	// found are used unless also given in the URL
+	alias := sshurl.Hostname()
+	if alias == "" {
+		alias = h.GetString("name")
+	}
+	h.SetDefault("hostname", alias)
 	h.SetDefault("port", 22)
 	h.SetDefault("username", viper.GetString("defaultuser"))
 	// XXX default to remote user's home dir, not local
 	h.SetDefault("geneos", host.Geneos())
 
-	// now disassemble URL
-	if sshurl.Hostname() == "" {
-		h.Set("hostname", h.GetString("name"))
+	for k, v := range host.SSHHostConfig(alias) {
+		h.Set(k, v)
 	}
 
+	// now disassemble URL
+
 	if sshurl.Port() != "" {
 		h.Set("port", sshurl.Port())
 	}
@@ -116,6 +177,61 @@ func addHost(h *host.Host, sshurl *url.URL) (err error) {
 		h.Set("geneos", sshurl.Path)
 	}
 
+	// the flag overrides any jump hosts in the URL
+	proxyjump := sshurl.Query().Get("proxyjump")
+	if addHostCmdProxyJump != "" {
+		proxyjump = addHostCmdProxyJump
+	}
+	if proxyjump != "" {
+		h.Set("proxyjump", proxyjump)
+		if _, err = h.ProxyJump(); err != nil {
+			return
+		}
+	}
+
+	if len(addHostCmdIdentityFiles) > 0 {
+		h.Set("identityfile", addHostCmdIdentityFiles)
+	}
+	if addHostCmdPassphraseFile != "" {
+		h.Set("passphrasefile", addHostCmdPassphraseFile)
+	}
+	if addHostCmdPassword {
+		h.Set("passwordauth", true)
+	}
+	if addHostCmdPasswordFile != "" {
+		h.Set("passwordfile", addHostCmdPasswordFile)
+	}
+	if addHostCmdSudo {
+		h.Set("sudo", true)
+	}
+	if addHostCmdSudoUser != "" {
+		h.Set("sudouser", addHostCmdSudoUser)
+	}
+	if addHostCmdSFTPServer != "" {
+		h.Set("sftpserver", addHostCmdSFTPServer)
+	}
+
+	// check the host keys of the host, and any jump hosts, before
+	// going further, asking to accept any that are not known
+	keys, err := h.CheckHostKeys(func(k host.HostKey) (bool, error) {
+		if k.Status == host.HostKeyChanged {
+			return false, fmt.Errorf("host key for %s has changed, check it with 'geneos host keys -V'", k.Address)
+		}
+		if addHostCmdAcceptNew || k.Strict == "accept-new" {
+			log.Printf("accepting %s key %s for %s", k.Key.Type(), ssh.FingerprintSHA256(k.Key), k.Address)
+			return true, nil
+		}
+		return confirmHostKey(k)
+	})
+	if err != nil {
+		return
+	}
+	for _, k := range keys {
+		if k.Status == host.HostKeyUnknown {
+			return fmt.Errorf("host key for %s not accepted", k.Address)
+		}
+	}
+
 	// once we are bootstrapped, read os-release info and re-write config
 	if err = h.GetOSReleaseEnv(); err != nil {
 		return
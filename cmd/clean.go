@@ -46,10 +46,11 @@ geneos clean --purge netprobe`,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "true",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, cleanInstance, args, params)
+		return outputResults(instance.ForAllResults(ct, cleanInstance, args, params))
 	},
 }
 
@@ -62,6 +63,6 @@ func init() {
 
 var cleanCmdPurge bool
 
-func cleanInstance(c geneos.Instance, params []string) (err error) {
+func cleanInstance(c geneos.Instance, params []string) instance.Result {
 	return instance.Clean(c, geneos.Restart(cleanCmdPurge))
 }
@@ -41,7 +41,7 @@ execution.`,
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, commandInstance, args, params)
+		return instance.ForAll(ct, commandInstance, args, params, geneos.Parallel(1))
 	},
 }
 
@@ -24,6 +24,7 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
 	"wonderland.org/geneos/internal/instance"
 )
 
@@ -41,10 +42,11 @@ partial deletion cannot be protected against.`,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "true",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, deleteInstance, args, params)
+		return outputResults(instance.ForAllResults(ct, deleteInstance, args, params))
 	},
 }
 
@@ -57,24 +59,26 @@ func init() {
 
 var deleteCmdForce bool
 
-func deleteInstance(c geneos.Instance, params []string) (err error) {
+func deleteInstance(c geneos.Instance, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "delete")
 	if deleteCmdForce {
 		if c.Type().RealComponent {
-			if err = instance.Stop(c, false); err != nil {
-				return
+			if r := instance.Stop(c, false); r.Outcome == instance.Failed {
+				return result.Fail(r.Err)
 			}
 		}
 	}
 
 	if deleteCmdForce || instance.IsDisabled(c) {
-		if err = c.Host().RemoveAll(c.Home()); err != nil {
-			return
+		if err := c.Host().RemoveAll(c.Home()); err != nil {
+			return result.Fail(err)
 		}
-		log.Printf("%s deleted %s:%s", c, c.Host().String(), c.Home())
 		c.Unload()
-		return nil
+		if host.DryRun {
+			return result.Set(instance.OK, "would delete %s:%s", c.Host().String(), c.Home())
+		}
+		return result.Set(instance.OK, "deleted %s:%s", c.Host().String(), c.Home())
 	}
 
-	log.Println(c, "must use -F or instance must be be disabled before delete")
-	return nil
+	return result.Set(instance.Skipped, "must use -F or instance must be be disabled before delete")
 }
@@ -86,8 +86,10 @@ func commandDeleteHost(_ *geneos.Component, args []string, params []string) (err
 		// stop and/or delete instances on host
 		if deleteHostCmdStop {
 			for _, c := range instance.GetAll(h, nil) {
-				if err = instance.Stop(c, false); err != nil {
-					return
+				if r := instance.Stop(c, false); r.Outcome == instance.Failed {
+					return r.Err
+				} else if r.Message != "" {
+					log.Println(r)
 				}
 				if deleteHostCmdRecurse {
 					if deleteHostCmdForce || instance.IsDisabled(c) {
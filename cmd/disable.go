@@ -22,9 +22,6 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"errors"
-	"os"
-
 	"github.com/spf13/cobra"
 	"wonderland.org/geneos/internal/geneos"
 	"wonderland.org/geneos/internal/instance"
@@ -43,7 +40,7 @@ var disableCmd = &cobra.Command{
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, disableInstance, args, params)
+		return outputResults(instance.ForAllResults(ct, disableInstance, args, params))
 	},
 }
 
@@ -52,19 +49,23 @@ func init() {
 	disableCmd.Flags().SortFlags = false
 }
 
-func disableInstance(c geneos.Instance, params []string) (err error) {
+func disableInstance(c geneos.Instance, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "disable")
 	if instance.IsDisabled(c) {
-		return nil
+		return result.Set(instance.Unchanged, "")
 	}
 
 	uid, gid, _, err := utils.GetIDs(c.V().GetString("user"))
 	if err != nil {
-		return
+		return result.Fail(err)
 	}
 
-	if err = instance.Stop(c, false); err != nil && !errors.Is(err, os.ErrProcessDone) {
-		return
+	// keep the stop message, if any, as the only output
+	stop := instance.Stop(c, false)
+	if stop.Outcome == instance.Failed {
+		return result.Fail(stop.Err)
 	}
+	result.Message = stop.Message
 
 	disablePath := instance.ConfigPathWithExt(c, geneos.DisableExtension)
 
@@ -72,13 +73,14 @@ func disableInstance(c geneos.Instance, params []string) (err error) {
 
 	f, err := h.Create(disablePath, 0664)
 	if err != nil {
-		return err
+		return result.Fail(err)
 	}
 	f.Close()
 
 	if utils.IsSuperuser() {
 		if err = h.Chown(disablePath, uid, gid); err != nil {
 			h.Remove(disablePath)
+			return result.Fail(err)
 		}
 	}
 
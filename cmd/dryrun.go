@@ -0,0 +1,70 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"wonderland.org/geneos/internal/host"
+	"wonderland.org/geneos/internal/instance"
+)
+
+// dryRun is set by the global --dry-run flag
+var dryRun bool
+
+// setDryRun sets up dry run mode for cmd. Commands with the "dryrun"
+// annotation run as normal but every change to files, process or
+// remote command is reported instead of made. Other commands that take
+// wildcard instance names only report the instances they would act on
+// and the rest are not supported.
+func setDryRun(cmd *cobra.Command) error {
+	switch {
+	case cmd.Annotations["dryrun"] == "true":
+		host.DryRun = true
+	case cmd.Annotations["wildcard"] == "true":
+		dryRunSelection(cmd)
+	default:
+		return fmt.Errorf("%s does not support --dry-run: %w", cmd.CommandPath(), ErrNotSupported)
+	}
+	return nil
+}
+
+// dryRunSelection replaces the action of cmd, which takes wildcard
+// instance names, with a report of the instances that the arguments
+// resolved to
+func dryRunSelection(cmd *cobra.Command) {
+	action := cmd.Name()
+	cmd.Run = nil
+	cmd.RunE = func(cmd *cobra.Command, _ []string) error {
+		ct, args, params := cmdArgsParams(cmd)
+		cs, err := instance.MatchArgs(ct, args, params)
+		if err != nil {
+			return err
+		}
+		var results instance.Results
+		for _, c := range cs {
+			results = append(results, instance.NewResult(c, action).Set(instance.Skipped, "would %s (dry run)", action))
+		}
+		return outputResults(results, nil)
+	}
+}
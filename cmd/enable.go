@@ -44,7 +44,7 @@ when the -S flag is used.`,
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, enableInstance, args, params)
+		return outputResults(instance.ForAllResults(ct, enableInstance, args, params))
 	},
 }
 
@@ -57,10 +57,16 @@ func init() {
 
 var enableCmdStart bool
 
-func enableInstance(c geneos.Instance, params []string) (err error) {
-	err = c.Host().Remove(instance.ConfigPathWithExt(c, geneos.DisableExtension))
-	if (err == nil || errors.Is(err, os.ErrNotExist)) && enableCmdStart {
-		instance.Start(c)
+func enableInstance(c geneos.Instance, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "enable")
+	err := c.Host().Remove(instance.ConfigPathWithExt(c, geneos.DisableExtension))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return result.Fail(err)
 	}
-	return nil
+	if enableCmdStart {
+		start := instance.Start(c)
+		start.Action = result.Action
+		return start
+	}
+	return
 }
@@ -0,0 +1,234 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+	"wonderland.org/geneos/internal/instance"
+)
+
+// exportComposeCmd represents the export compose command
+var exportComposeCmd = &cobra.Command{
+	Use:   "compose [-f FILE] [--image IMAGE] [TYPE] [NAME...]",
+	Short: "Export instances as a Docker Compose file",
+	Long: `Write a Docker Compose file with one service per matching local
+instance, named TYPE-NAME, to stdout or FILE.
+
+Each service runs the same command line and environment as the start
+command in an image built from the Dockerfile in the geneos source
+tree, which defaults to "geneos". The instance directory and the
+package directory for its version are mounted at the same paths as on
+this host, so the file is only suitable for use on this host. The
+configured port is published and the container runs as the owner of
+the instance directory.
+
+SANs are made to depend on the exported gateways that they connect to.
+The gateway services are given network aliases for the gateway host
+names in the SAN configurations, except for "localhost" which cannot
+be used between containers and must be changed.`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "true",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ct, args, params := cmdArgsParams(cmd)
+		return commandExportCompose(ct, args, params)
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(exportComposeCmd)
+
+	exportComposeCmd.Flags().StringVarP(&exportComposeCmdFile, "file", "f", "", "Write to FILE instead of stdout")
+	exportComposeCmd.Flags().StringVar(&exportComposeCmdImage, "image", "geneos", "Container image for all services")
+	exportComposeCmd.Flags().SortFlags = false
+}
+
+var exportComposeCmdFile, exportComposeCmdImage string
+
+type composeFile struct {
+	Services map[string]*composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Image       string                     `yaml:"image"`
+	User        string                     `yaml:"user,omitempty"`
+	WorkingDir  string                     `yaml:"working_dir"`
+	Command     []string                   `yaml:"command"`
+	Environment map[string]string          `yaml:"environment,omitempty"`
+	Ports       []string                   `yaml:"ports,omitempty"`
+	Volumes     []string                   `yaml:"volumes"`
+	DependsOn   []string                   `yaml:"depends_on,omitempty"`
+	Networks    map[string]*composeNetwork `yaml:"networks,omitempty"`
+
+	// used for wiring dependencies, not output
+	c geneos.Instance
+}
+
+type composeNetwork struct {
+	Aliases []string `yaml:"aliases,omitempty"`
+}
+
+var composeNameRE = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// composeServiceName returns a valid service name for c
+func composeServiceName(c geneos.Instance) string {
+	return c.Type().String() + "-" + composeNameRE.ReplaceAllString(c.Name(), "_")
+}
+
+func commandExportCompose(ct *geneos.Component, args, params []string) (err error) {
+	compose := composeFile{Services: make(map[string]*composeService)}
+	var mutex sync.Mutex
+
+	err = instance.ForAll(ct, func(c geneos.Instance, _ []string) error {
+		if c.Host() != host.LOCAL {
+			log.Println(c, "is not local, skipping")
+			return nil
+		}
+		s, err := newComposeService(c)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		compose.Services[composeServiceName(c)] = s
+		mutex.Unlock()
+		return nil
+	}, args, params)
+	if err != nil {
+		return
+	}
+
+	composeDependencies(compose.Services)
+
+	b, err := yaml.Marshal(compose)
+	if err != nil {
+		return
+	}
+	b = append([]byte("# created by geneos export compose\n"), b...)
+
+	if exportComposeCmdFile == "" {
+		_, err = os.Stdout.Write(b)
+		return
+	}
+	return host.LOCAL.WriteFile(exportComposeCmdFile, b, 0664)
+}
+
+func newComposeService(c geneos.Instance) (s *composeService, err error) {
+	cmd, env := instance.BuildCmd(c)
+	if cmd == nil {
+		return nil, fmt.Errorf("%s: buildCommand returned nil", c)
+	}
+
+	s = &composeService{
+		Image:      exportComposeCmdImage,
+		WorkingDir: c.Home(),
+		Command:    append([]string{cmd.Path}, cmd.Args[1:]...),
+		c:          c,
+	}
+
+	if st, err := c.Host().Stat(c.Home()); err == nil {
+		s.User = fmt.Sprintf("%d:%d", st.Uid, st.Gid)
+	}
+
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if s.Environment == nil {
+			s.Environment = make(map[string]string)
+		}
+		s.Environment[kv[0]] = kv[1]
+	}
+
+	if port := c.V().GetInt("port"); port != 0 {
+		s.Ports = []string{fmt.Sprintf("%d:%d", port, port)}
+	}
+
+	s.Volumes = []string{c.Home() + ":" + c.Home()}
+	if install, version := c.V().GetString("install"), c.V().GetString("version"); install != "" && version != "" {
+		pkg := filepath.Join(install, version)
+		s.Volumes = append(s.Volumes, pkg+":"+pkg+":ro")
+	}
+	return
+}
+
+// composeDependencies makes each SAN depend on the gateways it connects
+// to, matching by port and host name, and adds network aliases to the
+// gateways for the host names used
+func composeDependencies(services map[string]*composeService) {
+	hostname, _ := os.Hostname()
+	for _, s := range services {
+		if s.c.Type().String() != "san" {
+			continue
+		}
+		deps := make(map[string]bool)
+		for gwhost, gwport := range s.c.V().GetStringMapString("gateways") {
+			loopback := gwhost == "localhost" || gwhost == "127.0.0.1" || gwhost == "::1"
+			for gname, g := range services {
+				if g.c.Type().String() != "gateway" || g.c.V().GetString("port") != gwport {
+					continue
+				}
+				if !loopback && gwhost != gname && gwhost != g.c.Name() && gwhost != hostname {
+					continue
+				}
+				deps[gname] = true
+				if loopback {
+					log.Printf("%s connects to gateway on %q, change to %q to use in containers", s.c, gwhost, gname)
+				} else if gwhost != gname {
+					composeAlias(g, gwhost)
+				}
+			}
+		}
+		for d := range deps {
+			s.DependsOn = append(s.DependsOn, d)
+		}
+		sort.Strings(s.DependsOn)
+	}
+}
+
+// composeAlias adds alias to the default network aliases of service s
+func composeAlias(s *composeService, alias string) {
+	if s.Networks == nil {
+		s.Networks = map[string]*composeNetwork{"default": {}}
+	}
+	n := s.Networks["default"]
+	for _, a := range n.Aliases {
+		if a == alias {
+			return
+		}
+	}
+	n.Aliases = append(n.Aliases, alias)
+	sort.Strings(n.Aliases)
+}
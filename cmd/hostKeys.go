@@ -0,0 +1,232 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+)
+
+// hostKeysCmd represents the host keys command
+var hostKeysCmd = &cobra.Command{
+	Use:   "keys [-V | -R [-y]] [NAME...]",
+	Short: "List, verify and rotate the SSH host keys of remote hosts",
+	Long: `List the SSH host keys recorded for the named remote hosts, or all
+remote hosts, and any jump hosts used to reach them. Keys are looked for
+in both your ~/.ssh/known_hosts file and the one managed by geneos,
+geneos-known_hosts in your user configuration directory.
+
+With -V each host is contacted and the key it presents is checked
+against the recorded keys. With -R keys that are unknown or have
+changed are shown and, once confirmed, recorded in the geneos
+known_hosts file replacing any key recorded there before. Use -y to
+record them without asking.`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "false",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		_, args := cmdArgs(cmd)
+		return commandHostKeys(args)
+	},
+}
+
+func init() {
+	hostCmd.AddCommand(hostKeysCmd)
+
+	hostKeysCmd.Flags().BoolVarP(&hostKeysCmdVerify, "verify", "V", false, "Check the key each host presents against the recorded keys")
+	hostKeysCmd.Flags().BoolVarP(&hostKeysCmdRotate, "rotate", "R", false, "Record unknown or changed keys after confirmation")
+	hostKeysCmd.Flags().BoolVarP(&hostKeysCmdYes, "yes", "y", false, "Record unknown or changed keys without asking")
+	hostKeysCmd.Flags().SortFlags = false
+}
+
+var hostKeysCmdVerify, hostKeysCmdRotate, hostKeysCmdYes bool
+
+type hostKeysType struct {
+	Host        string
+	Jump        bool
+	Address     string
+	Type        string
+	Fingerprint string
+	File        string `json:",omitempty"`
+	Status      string `json:",omitempty"`
+}
+
+func commandHostKeys(args []string) (err error) {
+	if hostKeysCmdVerify && hostKeysCmdRotate {
+		return fmt.Errorf("-V and -R cannot be used together: %w", geneos.ErrInvalidArgs)
+	}
+	if hostKeysCmdYes {
+		hostKeysCmdRotate = true
+	}
+
+	var hosts []*host.Host
+	if len(args) == 0 {
+		for _, h := range host.AllHosts() {
+			if h != host.LOCAL {
+				hosts = append(hosts, h)
+			}
+		}
+		sort.Slice(hosts, func(i, j int) bool { return hosts[i].String() < hosts[j].String() })
+	}
+	for _, name := range args {
+		h := host.Get(name)
+		if h == host.LOCAL || !h.Exists() {
+			return fmt.Errorf("%q is not a known remote host: %w", name, geneos.ErrInvalidArgs)
+		}
+		hosts = append(hosts, h)
+	}
+
+	var rows []hostKeysType
+	var failed int
+	for _, h := range hosts {
+		var keys []host.HostKey
+		switch {
+		case hostKeysCmdRotate:
+			keys, err = h.CheckHostKeys(func(k host.HostKey) (bool, error) {
+				if hostKeysCmdYes {
+					return true, nil
+				}
+				return confirmHostKey(k)
+			})
+		case hostKeysCmdVerify:
+			keys, err = h.CheckHostKeys(nil)
+		default:
+			keys, err = h.HostKeys()
+		}
+		if err != nil {
+			logError.Printf("%s: %s", h, err)
+			failed++
+		}
+		for _, k := range keys {
+			if !hostKeysCmdRotate && !hostKeysCmdVerify {
+				if len(k.Recorded) == 0 {
+					rows = append(rows, hostKeysType{Host: h.String(), Jump: k.Jump, Address: k.Address, File: "not recorded"})
+				}
+				for _, r := range k.Recorded {
+					file := r.File
+					if r.Marker != "" {
+						file += " (@" + r.Marker + ")"
+					}
+					rows = append(rows, hostKeysType{h.String(), k.Jump, k.Address, r.Key.Type(), ssh.FingerprintSHA256(r.Key), file, ""})
+				}
+				continue
+			}
+			row := hostKeysType{Host: h.String(), Jump: k.Jump, Address: k.Address, Status: k.Status}
+			if k.Key != nil {
+				row.Type, row.Fingerprint = k.Key.Type(), ssh.FingerprintSHA256(k.Key)
+			}
+			if k.Status == host.HostKeyUnknown || k.Status == host.HostKeyChanged {
+				failed++
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	columns := []string{"Host", "Hop", "Address", "Type", "Fingerprint", "File"}
+	if hostKeysCmdVerify || hostKeysCmdRotate {
+		columns[5] = "Status"
+	}
+	switch outputFormat {
+	case "json":
+		jsonEncoder = json.NewEncoder(outputWriter())
+		for _, r := range rows {
+			jsonEncoder.Encode(r)
+		}
+	case "csv":
+		csvWriter = csv.NewWriter(outputWriter())
+		csvWriter.Write(columns)
+		for _, r := range rows {
+			csvWriter.Write(r.fields())
+		}
+		csvWriter.Flush()
+	default:
+		w := tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+		for _, r := range rows {
+			fmt.Fprintln(w, strings.Join(r.fields(), "\t"))
+		}
+		w.Flush()
+	}
+
+	if failed > 0 && (hostKeysCmdVerify || hostKeysCmdRotate) {
+		return fmt.Errorf("host key verification failed for %d host(s)", failed)
+	}
+	return nil
+}
+
+func (r hostKeysType) fields() []string {
+	hop := "host"
+	if r.Jump {
+		hop = "jump"
+	}
+	last := r.File
+	if hostKeysCmdVerify || hostKeysCmdRotate {
+		last = r.Status
+	}
+	return []string{r.Host, hop, r.Address, r.Type, r.Fingerprint, last}
+}
+
+// confirmHostKey shows an unknown or changed host key and asks the user
+// whether to accept it. It fails if there is no terminal to ask on.
+func confirmHostKey(k host.HostKey) (ok bool, err error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("host key for %s is %s and there is no terminal to confirm it", k.Address, k.Status)
+	}
+
+	if k.Status == host.HostKeyChanged {
+		fmt.Fprintf(os.Stderr, "The host key for '%s' has changed.\n", k.Address)
+		for _, r := range k.Recorded {
+			fmt.Fprintf(os.Stderr, "The %s key fingerprint recorded in %s is %s.\n", r.Key.Type(), r.File, ssh.FingerprintSHA256(r.Key))
+		}
+		fmt.Fprintf(os.Stderr, "The new %s key fingerprint is %s.\n", k.Key.Type(), ssh.FingerprintSHA256(k.Key))
+		fmt.Fprintf(os.Stderr, "Do you want to record the new key (yes/no)? ")
+	} else {
+		fmt.Fprintf(os.Stderr, "The authenticity of host '%s' can't be established.\n", k.Address)
+		fmt.Fprintf(os.Stderr, "%s key fingerprint is %s.\n", k.Key.Type(), ssh.FingerprintSHA256(k.Key))
+		fmt.Fprintf(os.Stderr, "Are you sure you want to continue connecting (yes/no)? ")
+	}
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "yes", "y":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -90,7 +90,8 @@ func commandImport(ct *geneos.Component, args []string, params []string) (err er
 		return
 	}
 
-	return instance.ForAll(ct, importInstance, args, params)
+	// serial as a source may be stdin
+	return instance.ForAll(ct, importInstance, args, params, geneos.Parallel(1))
 }
 
 // args are instance [file...]
@@ -131,6 +131,7 @@ var initCmdExtras = instance.ExtraConfigValues{
 	Envs:       instance.StringSliceValues{},
 	Variables:  instance.VarValues{},
 	Types:      instance.StringSliceValues{},
+	Labels:     instance.LabelValues{},
 }
 
 //
@@ -63,6 +63,7 @@ applies when using -U.
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "false",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
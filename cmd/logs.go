@@ -97,12 +97,12 @@ func commandLogs(ct *geneos.Component, args []string, params []string) (err erro
 
 	switch {
 	case logCmdCat:
-		err = instance.ForAll(ct, logCatInstance, args, params)
+		err = instance.ForAll(ct, logCatInstance, args, params, geneos.Parallel(1))
 	case logCmdFollow:
 		// never returns
 		err = followLogs(ct, args, params)
 	default:
-		err = instance.ForAll(ct, logTailInstance, args, params)
+		err = instance.ForAll(ct, logTailInstance, args, params, geneos.Parallel(1))
 	}
 
 	return
@@ -121,7 +121,7 @@ func followLog(c geneos.Instance) (err error) {
 func followLogs(ct *geneos.Component, args, params []string) (err error) {
 	done := make(chan bool)
 	tails = watchLogs()
-	if err = instance.ForAll(ct, logFollowInstance, args, params); err != nil {
+	if err = instance.ForAll(ct, logFollowInstance, args, params, geneos.Parallel(1)); err != nil {
 		log.Println(err)
 	}
 	<-done
@@ -26,6 +26,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"sync"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
@@ -64,23 +66,56 @@ var lsTabWriter *tabwriter.Writer
 var csvWriter *csv.Writer
 var jsonEncoder *json.Encoder
 
+type lsType struct {
+	Type     string
+	Name     string
+	Disabled string
+	Host     string
+	Port     int64
+	Version  string
+	Home     string
+	Labels   string
+}
+
+// rows are collected by concurrent workers and output once sorted
+var lsRows []lsType
+var lsRowsMutex sync.Mutex
+
 func commandLS(ct *geneos.Component, args []string, params []string) (err error) {
+	lsCmdJSON = lsCmdJSON || outputFormat == "json"
+	lsCmdCSV = lsCmdCSV || outputFormat == "csv"
+	lsRows = nil
+	err = instance.ForAll(ct, lsInstance, args, params)
+	sort.Slice(lsRows, func(i, j int) bool {
+		return lessInstance(lsRows[i].Type, lsRows[i].Name, lsRows[i].Host, lsRows[j].Type, lsRows[j].Name, lsRows[j].Host)
+	})
+
 	switch {
 	case lsCmdJSON:
-		jsonEncoder = json.NewEncoder(log.Writer())
+		jsonEncoder = json.NewEncoder(outputWriter())
 		if lsCmdIndent {
 			jsonEncoder.SetIndent("", "    ")
 		}
-		err = instance.ForAll(ct, lsInstanceJSON, args, params)
+		for _, r := range lsRows {
+			jsonEncoder.Encode(r)
+		}
 	case lsCmdCSV:
-		csvWriter = csv.NewWriter(log.Writer())
-		csvWriter.Write([]string{"Type", "Name", "Disabled", "Host", "Port", "Version", "Home"})
-		err = instance.ForAll(ct, lsInstanceCSV, args, params)
+		csvWriter = csv.NewWriter(outputWriter())
+		csvWriter.Write([]string{"Type", "Name", "Disabled", "Host", "Port", "Version", "Home", "Labels"})
+		for _, r := range lsRows {
+			csvWriter.Write([]string{r.Type, r.Name, r.Disabled, r.Host, fmt.Sprint(r.Port), r.Version, r.Home, r.Labels})
+		}
 		csvWriter.Flush()
 	default:
-		lsTabWriter = tabwriter.NewWriter(log.Writer(), 3, 8, 2, ' ', 0)
-		fmt.Fprintf(lsTabWriter, "Type\tName\tHost\tPort\tVersion\tHome\n")
-		err = instance.ForAll(ct, lsInstancePlain, args, params)
+		lsTabWriter = tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
+		fmt.Fprintf(lsTabWriter, "Type\tName\tHost\tPort\tVersion\tHome\tLabels\n")
+		for _, r := range lsRows {
+			name := r.Name
+			if r.Disabled == "Y" {
+				name += "*"
+			}
+			fmt.Fprintf(lsTabWriter, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n", r.Type, name, r.Host, r.Port, r.Version, r.Home, r.Labels)
+		}
 		lsTabWriter.Flush()
 	}
 	if err == os.ErrNotExist {
@@ -89,42 +124,30 @@ func commandLS(ct *geneos.Component, args []string, params []string) (err error)
 	return
 }
 
-func lsInstancePlain(c geneos.Instance, params []string) (err error) {
-	var suffix string
-	if instance.IsDisabled(c) {
-		suffix = "*"
-	}
-	base, underlying, _ := instance.Version(c)
-	fmt.Fprintf(lsTabWriter, "%s\t%s\t%s\t%d\t%s:%s\t%s\n", c.Type(), c.Name()+suffix, c.Host(), c.V().GetInt("port"), base, underlying, c.Home())
+func lsInstance(c geneos.Instance, params []string) (err error) {
+	row := newLsType(c)
+	lsRowsMutex.Lock()
+	lsRows = append(lsRows, row)
+	lsRowsMutex.Unlock()
 	return
 }
 
-func lsInstanceCSV(c geneos.Instance, params []string) (err error) {
+func newLsType(c geneos.Instance) lsType {
 	var dis string = "N"
 	if instance.IsDisabled(c) {
 		dis = "Y"
 	}
 	base, underlying, _ := instance.Version(c)
-	csvWriter.Write([]string{c.Type().String(), c.Name(), dis, c.Host().String(), fmt.Sprint(c.V().GetInt("port")), fmt.Sprintf("%s:%s", base, underlying), c.Home()})
-	return
+	return lsType{c.Type().String(), c.Name(), dis, c.Host().String(), c.V().GetInt64("port"), fmt.Sprintf("%s:%s", base, underlying), c.Home(), instance.LabelsString(c)}
 }
 
-type lsType struct {
-	Type     string
-	Name     string
-	Disabled string
-	Host     string
-	Port     int64
-	Version  string
-	Home     string
-}
-
-func lsInstanceJSON(c geneos.Instance, params []string) (err error) {
-	var dis string = "N"
-	if instance.IsDisabled(c) {
-		dis = "Y"
+// order output rows by type, name and then host
+func lessInstance(t1, n1, h1, t2, n2, h2 string) bool {
+	if t1 != t2 {
+		return t1 < t2
 	}
-	base, underlying, _ := instance.Version(c)
-	jsonEncoder.Encode(lsType{c.Type().String(), c.Name(), dis, c.Host().String(), c.V().GetInt64("port"), fmt.Sprintf("%s:%s", base, underlying), c.Home()})
-	return
+	if n1 != n2 {
+		return n1 < n2
+	}
+	return h1 < h2
 }
@@ -62,21 +62,23 @@ func init() {
 var lsHostCmdJSON, lsHostCmdCSV, lsHostCmdIndent bool
 
 func commandLSHost(ct *geneos.Component, args []string, params []string) (err error) {
+	lsHostCmdJSON = lsHostCmdJSON || outputFormat == "json"
+	lsHostCmdCSV = lsHostCmdCSV || outputFormat == "csv"
 	switch {
 	case lsHostCmdJSON:
-		jsonEncoder = json.NewEncoder(log.Writer())
+		jsonEncoder = json.NewEncoder(outputWriter())
 		if lsHostCmdIndent {
 			jsonEncoder.SetIndent("", "    ")
 		}
 		err = loopHosts(lsInstanceJSONHosts)
 	case lsHostCmdCSV:
-		csvWriter = csv.NewWriter(log.Writer())
-		csvWriter.Write([]string{"Type", "Name", "Disabled", "Username", "Hostname", "Port", "Directory"})
+		csvWriter = csv.NewWriter(outputWriter())
+		csvWriter.Write([]string{"Name", "Username", "Hostname", "Port", "Directory", "ProxyJump"})
 		err = loopHosts(lsInstanceCSVHosts)
 		csvWriter.Flush()
 	default:
-		lsTabWriter = tabwriter.NewWriter(log.Writer(), 3, 8, 2, ' ', 0)
-		fmt.Fprintf(lsTabWriter, "Name\tUsername\tHostname\tPort\tDirectory\n")
+		lsTabWriter = tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
+		fmt.Fprintf(lsTabWriter, "Name\tUsername\tHostname\tPort\tDirectory\tProxyJump\n")
 		err = loopHosts(lsInstancePlainHosts)
 		lsTabWriter.Flush()
 	}
@@ -97,12 +99,12 @@ func loopHosts(fn func(*host.Host) error) error {
 }
 
 func lsInstancePlainHosts(h *host.Host) (err error) {
-	fmt.Fprintf(lsTabWriter, "%s\t%s\t%s\t%d\t%s\n", h.GetString("name"), h.GetString("username"), h.GetString("hostname"), h.GetInt("port"), h.GetString("geneos"))
+	fmt.Fprintf(lsTabWriter, "%s\t%s\t%s\t%d\t%s\t%s\n", h.GetString("name"), h.GetString("username"), h.GetString("hostname"), h.GetInt("port"), h.GetString("geneos"), h.GetString("proxyjump"))
 	return
 }
 
 func lsInstanceCSVHosts(h *host.Host) (err error) {
-	csvWriter.Write([]string{h.String(), h.GetString("username"), h.GetString("hostname"), fmt.Sprint(h.GetInt("port")), h.GetString("geneos")})
+	csvWriter.Write([]string{h.String(), h.GetString("username"), h.GetString("hostname"), fmt.Sprint(h.GetInt("port")), h.GetString("geneos"), h.GetString("proxyjump")})
 	return
 }
 
@@ -112,9 +114,10 @@ type lsTypeHosts struct {
 	Hostname  string
 	Port      int64
 	Directory string
+	ProxyJump string `json:",omitempty"`
 }
 
 func lsInstanceJSONHosts(h *host.Host) (err error) {
-	jsonEncoder.Encode(lsTypeHosts{h.String(), h.GetString("username"), h.GetString("hostname"), h.GetInt64("port"), h.GetString("geneos")})
+	jsonEncoder.Encode(lsTypeHosts{h.String(), h.GetString("username"), h.GetString("hostname"), h.GetInt64("port"), h.GetString("geneos"), h.GetString("proxyjump")})
 	return
 }
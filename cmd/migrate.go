@@ -22,6 +22,8 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/spf13/cobra"
 	"wonderland.org/geneos/internal/geneos"
 	"wonderland.org/geneos/internal/instance"
@@ -41,7 +43,7 @@ take on the generic labels and are not a direct conversion.`,
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, migrateInstance, args, params)
+		return outputResults(instance.ForAllResults(ct, migrateInstance, args, params))
 	},
 }
 
@@ -50,9 +52,10 @@ func init() {
 	migrateCmd.Flags().SortFlags = false
 }
 
-func migrateInstance(c geneos.Instance, params []string) (err error) {
-	if err = instance.Migrate(c); err != nil {
-		log.Println(c, "cannot migrate configuration", err)
+func migrateInstance(c geneos.Instance, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "migrate")
+	if err := instance.Migrate(c); err != nil {
+		return result.Fail(fmt.Errorf("cannot migrate configuration: %w", err))
 	}
 	return
 }
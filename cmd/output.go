@@ -0,0 +1,101 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"wonderland.org/geneos/internal/instance"
+)
+
+// outputFormat is set by the global --output flag and is one of "text",
+// "json" or "csv"
+var outputFormat string
+
+type resultType struct {
+	Type    string
+	Name    string
+	Host    string
+	Action  string
+	Outcome string
+	PID     int
+	Message string
+	Error   string
+}
+
+// outputWriter returns the destination for command output. Structured
+// output always goes to stdout, while log messages are sent to stderr,
+// so that it can be piped to other programs.
+func outputWriter() io.Writer {
+	if outputFormat == "text" {
+		return log.Writer()
+	}
+	return os.Stdout
+}
+
+// outputResults renders results in the selected output format and
+// passes through err. Failures are not shown in text format as the
+// returned error reports them.
+func outputResults(results instance.Results, err error) error {
+	switch outputFormat {
+	case "json":
+		jsonEncoder = json.NewEncoder(outputWriter())
+		for _, r := range results {
+			jsonEncoder.Encode(newResultType(r))
+		}
+	case "csv":
+		csvWriter = csv.NewWriter(outputWriter())
+		csvWriter.Write([]string{"Type", "Name", "Host", "Action", "Outcome", "PID", "Message", "Error"})
+		for _, r := range results {
+			t := newResultType(r)
+			csvWriter.Write([]string{t.Type, t.Name, t.Host, t.Action, t.Outcome, fmt.Sprint(t.PID), t.Message, t.Error})
+		}
+		csvWriter.Flush()
+	default:
+		for _, r := range results {
+			if r.Message != "" && r.Outcome != instance.Failed {
+				log.Println(r)
+			}
+		}
+	}
+	return err
+}
+
+func newResultType(r instance.Result) (t resultType) {
+	t = resultType{
+		Type:    r.Instance.Type().String(),
+		Name:    r.Instance.Name(),
+		Host:    r.Instance.Host().String(),
+		Action:  r.Action,
+		Outcome: string(r.Outcome),
+		PID:     r.PID,
+		Message: r.Message,
+	}
+	if r.Err != nil {
+		t.Error = r.Err.Error()
+	}
+	return
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/spf13/cobra"
 	"wonderland.org/geneos/internal/geneos"
@@ -25,9 +28,15 @@ import (
 //
 // a bare argument with a '@' prefix means all instance of type on a host
 //
-func parseArgs(cmd *cobra.Command, rawargs []string) {
+// instance names may be glob patterns or, with a '~' prefix, regular
+// expressions, which are expanded to the matching instances
+//
+// if any label selectors or groups are given then the resulting names
+// are limited to the matching instances, each as TYPE:NAME@HOST
+//
+func parseArgs(cmd *cobra.Command, rawargs []string) (err error) {
 	var wild bool
-	var newnames []string
+	var newnames, unmatched []string
 
 	var ct *geneos.Component
 	var args, params []string
@@ -99,6 +108,26 @@ func parseArgs(cmd *cobra.Command, rawargs []string) {
 			// @all is not valid - should be no arg
 			var nargs []string
 			for _, arg := range args {
+				// expand name patterns to the matching instances,
+				// leaving any that match nothing to become params
+				if instance.IsPattern(arg) {
+					_, local, _ := instance.SplitName(arg, host.ALL)
+					if _, err = instance.NameMatcher(local); err != nil {
+						return
+					}
+					cs := instance.MatchAll(ct, arg)
+					if len(cs) == 0 {
+						log.Println("no match for", arg)
+						unmatched = append(unmatched, arg)
+						nargs = append(nargs, arg)
+						continue
+					}
+					wild = true
+					for _, c := range cs {
+						nargs = append(nargs, c.String())
+					}
+					continue
+				}
 				// check if not valid first and leave unchanged, skip
 				if !(strings.HasPrefix(arg, "@") || instance.ValidInstanceName(arg)) {
 					logDebug.Println("leaving unchanged:", arg)
@@ -181,6 +210,18 @@ func parseArgs(cmd *cobra.Command, rawargs []string) {
 	}
 	args = newnames
 
+	// do not fall back to all instances when patterns matched nothing
+	if len(args) == 0 && len(unmatched) > 0 {
+		return fmt.Errorf("no instances match %s", strings.Join(unmatched, " "))
+	}
+
+	if a["wildcard"] == "true" && (len(selectorLabels) > 0 || len(selectorGroups) > 0) {
+		if args, err = selectNames(ct, args); err != nil {
+			return
+		}
+		wild = true
+	}
+
 	jsonargs, _ = json.Marshal(args)
 	a["args"] = string(jsonargs)
 	jsonparams, _ := json.Marshal(params)
@@ -198,6 +239,7 @@ func parseArgs(cmd *cobra.Command, rawargs []string) {
 	}
 
 	logDebug.Println("ct, args, params", ct, args, params)
+	return
 }
 
 func cmdArgs(cmd *cobra.Command) (ct *geneos.Component, args []string) {
@@ -216,3 +258,51 @@ func cmdArgsParams(cmd *cobra.Command) (ct *geneos.Component, args, params []str
 	}
 	return
 }
+
+// the instance selection flags, added to all commands that take
+// wildcard instance names
+var selectorLabels, selectorGroups []string
+
+var addSelectorFlagsOnce sync.Once
+
+// addSelectorFlags adds the --selector and --group flags to all the
+// commands under cmd that take wildcard instance names. They have no
+// short forms so that they mean the same on every command.
+func addSelectorFlags(cmd *cobra.Command) {
+	addSelectorFlagsOnce.Do(func() {
+		addSelectorFlagsTo(cmd)
+	})
+}
+
+func addSelectorFlagsTo(cmd *cobra.Command) {
+	for _, c := range cmd.Commands() {
+		addSelectorFlagsTo(c)
+	}
+	if cmd.Annotations["wildcard"] != "true" {
+		return
+	}
+	cmd.Flags().StringArrayVar(&selectorLabels, "selector", nil, "Select instances matching `LABELS`, e.g. env=prod,region!=apac")
+	cmd.Flags().StringArrayVar(&selectorGroups, "group", nil, "Select instances in the named `GROUP`")
+}
+
+// selectNames returns the names, as TYPE:NAME@HOST, of the instances
+// of type ct matching args that also match the selection flags. It is
+// an error if no instances match.
+func selectNames(ct *geneos.Component, args []string) (names []string, err error) {
+	sel, err := instance.ParseSelector(strings.Join(selectorLabels, ","))
+	if err != nil {
+		return
+	}
+	cs, err := instance.Select(ct, args, sel, selectorGroups)
+	if err != nil && err != os.ErrNotExist {
+		return
+	}
+	if len(cs) == 0 {
+		return nil, fmt.Errorf("no instances match the selection")
+	}
+	for _, c := range cs {
+		names = append(names, c.String())
+	}
+	logDebug.Println("selected:", names)
+	return
+}
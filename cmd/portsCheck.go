@@ -0,0 +1,207 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+	"wonderland.org/geneos/internal/instance"
+)
+
+// portsCheckCmd represents the ports check command
+var portsCheckCmd = &cobra.Command{
+	Use:   "check [TYPE] [NAME...]",
+	Short: "Report port duplicates and collisions",
+	Long: `Report ports configured for more than one instance on the same host
+and ports configured for an instance that are listening in another
+process. Ports are taken from the "port" setting of each instance and
+the listen ports in gateway setup files.
+
+All instances on the hosts of the matching instances are checked but
+only problems involving the matching instances are reported. The
+command fails if any problems are found.`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "true",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ct, args, params := cmdArgsParams(cmd)
+		return commandPortsCheck(ct, args, params)
+	},
+}
+
+func init() {
+	portsCmd.AddCommand(portsCheckCmd)
+	portsCheckCmd.Flags().SortFlags = false
+}
+
+type portsCheckType struct {
+	Host    string
+	Port    uint16
+	Type    string
+	Name    string
+	Source  string
+	Problem string
+}
+
+func commandPortsCheck(ct *geneos.Component, args, params []string) (err error) {
+	// the hosts and instances to check
+	matched := make(map[string]bool)
+	var hosts []*host.Host
+	err = instance.ForAll(ct, func(c geneos.Instance, _ []string) error {
+		matched[c.String()] = true
+		return nil
+	}, args, params, geneos.Parallel(1))
+	if err != nil {
+		if err == os.ErrNotExist {
+			err = nil
+		}
+		return
+	}
+	for _, h := range host.AllHosts() {
+		for _, c := range instance.GetAll(h, nil) {
+			if matched[c.String()] {
+				hosts = append(hosts, h)
+				break
+			}
+		}
+	}
+
+	var rows []portsCheckType
+	for _, h := range hosts {
+		uses, err := instance.PortUses(h)
+		if err != nil {
+			logError.Printf("%s: %s", h, err)
+			continue
+		}
+		rows = append(rows, portsCheckHost(h, uses, matched)...)
+		uninspected := make(map[string]bool)
+		for _, u := range uses {
+			if u.Uninspected && matched[u.Instance.String()] && !uninspected[u.Instance.String()] {
+				uninspected[u.Instance.String()] = true
+				log.Printf("%s: cannot read its sockets, listening ports not checked", u.Instance)
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Host != rows[j].Host {
+			return rows[i].Host < rows[j].Host
+		}
+		return rows[i].Port < rows[j].Port
+	})
+
+	switch outputFormat {
+	case "json":
+		jsonEncoder = json.NewEncoder(outputWriter())
+		for _, r := range rows {
+			jsonEncoder.Encode(r)
+		}
+	case "csv":
+		csvWriter = csv.NewWriter(outputWriter())
+		csvWriter.Write([]string{"Host", "Port", "Type", "Name", "Source", "Problem"})
+		for _, r := range rows {
+			csvWriter.Write([]string{r.Host, fmt.Sprint(r.Port), r.Type, r.Name, r.Source, r.Problem})
+		}
+		csvWriter.Flush()
+	default:
+		if len(rows) == 0 {
+			log.Println("no port problems found")
+			return
+		}
+		w := tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "Host\tPort\tType\tName\tSource\tProblem\n")
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", r.Host, r.Port, r.Type, r.Name, r.Source, r.Problem)
+		}
+		w.Flush()
+	}
+	if len(rows) > 0 {
+		return fmt.Errorf("%d port problems found", len(rows))
+	}
+	return
+}
+
+// portsCheckHost returns the problems with the ports on host h that
+// involve a matched instance
+func portsCheckHost(h *host.Host, uses []instance.PortUse, matched map[string]bool) (rows []portsCheckType) {
+	byPort := make(map[uint16][]instance.PortUse)
+	for _, u := range uses {
+		byPort[u.Port] = append(byPort[u.Port], u)
+	}
+
+	for port, us := range byPort {
+		// the distinct instances configured with this port
+		var configured []instance.PortUse
+		var listening []instance.PortUse
+		seen := make(map[string]bool)
+		for _, u := range us {
+			if u.Source == "listening" {
+				listening = append(listening, u)
+				continue
+			}
+			if !seen[u.Instance.String()] {
+				seen[u.Instance.String()] = true
+				configured = append(configured, u)
+			}
+		}
+
+		for _, u := range configured {
+			if !matched[u.Instance.String()] {
+				continue
+			}
+			row := portsCheckType{h.String(), port, u.Instance.Type().String(), u.Instance.Name(), u.Source, ""}
+			var problems []string
+			for _, o := range configured {
+				if o.Instance != u.Instance {
+					problems = append(problems, "also configured for "+o.Instance.String())
+				}
+			}
+			for _, l := range listening {
+				switch {
+				case l.Instance == u.Instance:
+				case l.Instance != nil:
+					problems = append(problems, fmt.Sprintf("listening in %s (PID %d)", l.Instance, l.PID))
+				case u.Uninspected:
+					// probably the instance itself, which could not be
+					// checked
+				default:
+					problems = append(problems, "listening in another process")
+				}
+			}
+			if len(problems) > 0 {
+				row.Problem = strings.Join(problems, ", ")
+				rows = append(rows, row)
+			}
+		}
+	}
+	return
+}
@@ -27,6 +27,8 @@ import (
 	"fmt"
 	"os"
 	"os/user"
+	"sort"
+	"sync"
 	"text/tabwriter"
 	"time"
 
@@ -37,9 +39,32 @@ import (
 
 // psCmd represents the ps command
 var psCmd = &cobra.Command{
-	Use:                   "ps [-c|-j [-i]] [TYPE] [NAMES...]",
-	Short:                 "List process information for instances, optionally in CSV or JSON format",
-	Long:                  `Show the status of the matching instances.`,
+	Use:   "ps [-s|-m|-V] [-c|-j [-i]] [TYPE] [NAMES...]",
+	Short: "List process information for instances, optionally in CSV or JSON format",
+	Long: `Show the status of the matching instances.
+
+With --sockets show the TCP and UDP sockets, IPv4 and IPv6, open by each
+running instance, one per line, instead of the process details.
+
+With --metrics also show the resource usage of each process: the CPU
+time as a percentage of the time since it started (like 'ps'), the
+resident and virtual memory sizes, the number of threads and open files
+and the uptime. The start time is then the real start time of the
+process. This works for remote hosts too but is slower as more files
+are read from each host. In JSON the sizes are in KiB and the uptime in
+seconds.
+
+With --verify check the PID file that 'start' writes in each instance
+directory against the running process and show the state of each
+instance, one of:
+
+  ok        the PID file matches the running process
+  missing   the instance is running but there is no PID file, for
+            example if it was started by an older version
+  stale     there is a PID file but the instance is not running
+  mismatch  the PID file does not match the running process, for
+            example after a restart by systemd
+  none      the instance is not running and there is no PID file`,
 	SilenceUsage:          true,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
@@ -54,13 +79,16 @@ var psCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(psCmd)
 
+	psCmd.Flags().BoolVarP(&psCmdSockets, "sockets", "s", false, "Show open sockets, listening and connected")
+	psCmd.Flags().BoolVarP(&psCmdMetrics, "metrics", "m", false, "Show resource usage: CPU, memory, threads, open files and uptime")
+	psCmd.Flags().BoolVarP(&psCmdVerify, "verify", "V", false, "Verify PID files against running processes")
 	psCmd.PersistentFlags().BoolVarP(&psCmdJSON, "json", "j", false, "Output JSON")
 	psCmd.PersistentFlags().BoolVarP(&psCmdIndent, "pretty", "i", false, "Indent / pretty print JSON")
 	psCmd.PersistentFlags().BoolVarP(&psCmdCSV, "csv", "c", false, "Output CSV")
 	psCmd.Flags().SortFlags = false
 }
 
-var psCmdJSON, psCmdIndent, psCmdCSV bool
+var psCmdJSON, psCmdIndent, psCmdCSV, psCmdSockets, psCmdMetrics, psCmdVerify bool
 
 var psTabWriter *tabwriter.Writer
 
@@ -69,28 +97,75 @@ type psType struct {
 	Name      string
 	Host      string
 	PID       string
+	Ports     []int `json:"-"`
 	User      string
 	Group     string
 	Starttime string
+	CPU       float64 `json:",omitempty"`
+	RSS       int64   `json:",omitempty"`
+	VSZ       int64   `json:",omitempty"`
+	Threads   int     `json:",omitempty"`
+	OpenFiles int     `json:",omitempty"`
+	Uptime    int64   `json:",omitempty"`
 	Version   string
 	Home      string
 }
 
+// rows are collected by concurrent workers and output once sorted
+var psRows []psType
+var psRowsMutex sync.Mutex
+
 func commandPS(ct *geneos.Component, args []string, params []string) (err error) {
+	psCmdJSON = psCmdJSON || outputFormat == "json"
+	psCmdCSV = psCmdCSV || outputFormat == "csv"
+	if psCmdSockets {
+		return commandPSSockets(ct, args, params)
+	}
+	if psCmdVerify {
+		return commandPSVerify(ct, args, params)
+	}
+	psRows = nil
+	err = instance.ForAll(ct, psInstance, args, params)
+	sort.Slice(psRows, func(i, j int) bool {
+		return lessInstance(psRows[i].Type, psRows[i].Name, psRows[i].Host, psRows[j].Type, psRows[j].Name, psRows[j].Host)
+	})
+
 	switch {
 	case psCmdJSON:
-		jsonEncoder = json.NewEncoder(log.Writer())
+		jsonEncoder = json.NewEncoder(outputWriter())
 		//jsonEncoder.SetIndent("", "    ")
-		err = instance.ForAll(ct, psInstanceJSON, args, params)
+		for _, r := range psRows {
+			jsonEncoder.Encode(r)
+		}
 	case psCmdCSV:
-		csvWriter = csv.NewWriter(log.Writer())
-		csvWriter.Write([]string{"Type", "Name", "Host", "PID", "User", "Group", "Starttime", "Version", "Home"})
-		err = instance.ForAll(ct, psInstanceCSV, args, params)
+		csvWriter = csv.NewWriter(outputWriter())
+		columns := []string{"Type", "Name", "Host", "PID", "User", "Group", "Starttime"}
+		if psCmdMetrics {
+			columns = append(columns, "CPU", "RSS", "VSZ", "Threads", "OpenFiles", "Uptime")
+		}
+		csvWriter.Write(append(columns, "Version", "Home"))
+		for _, r := range psRows {
+			row := []string{r.Type, r.Name, r.Host, r.PID, r.User, r.Group, r.Starttime}
+			if psCmdMetrics {
+				row = append(row, fmt.Sprintf("%.1f", r.CPU), fmt.Sprint(r.RSS), fmt.Sprint(r.VSZ), fmt.Sprint(r.Threads), fmt.Sprint(r.OpenFiles), fmt.Sprint(r.Uptime))
+			}
+			csvWriter.Write(append(row, r.Version, r.Home))
+		}
 		csvWriter.Flush()
 	default:
-		psTabWriter = tabwriter.NewWriter(log.Writer(), 3, 8, 2, ' ', 0)
-		fmt.Fprintf(psTabWriter, "Type\tName\tHost\tPID\tPorts\tUser\tGroup\tStarttime\tVersion\tHome\n")
-		err = instance.ForAll(ct, psInstancePlain, args, params)
+		psTabWriter = tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
+		if psCmdMetrics {
+			fmt.Fprintf(psTabWriter, "Type\tName\tHost\tPID\tPorts\tUser\tGroup\tStarttime\tCPU%%\tRSS\tVSZ\tThreads\tFiles\tUptime\tVersion\tHome\n")
+		} else {
+			fmt.Fprintf(psTabWriter, "Type\tName\tHost\tPID\tPorts\tUser\tGroup\tStarttime\tVersion\tHome\n")
+		}
+		for _, r := range psRows {
+			fmt.Fprintf(psTabWriter, "%s\t%s\t%s\t%s\t%v\t%s\t%s\t%s\t", r.Type, r.Name, r.Host, r.PID, r.Ports, r.User, r.Group, r.Starttime)
+			if psCmdMetrics {
+				fmt.Fprintf(psTabWriter, "%.1f\t%s\t%s\t%d\t%d\t%v\t", r.CPU, humanKiB(r.RSS), humanKiB(r.VSZ), r.Threads, r.OpenFiles, time.Duration(r.Uptime)*time.Second)
+			}
+			fmt.Fprintf(psTabWriter, "%s\t%s\n", r.Version, r.Home)
+		}
 		psTabWriter.Flush()
 	}
 	if err == os.ErrNotExist {
@@ -99,13 +174,30 @@ func commandPS(ct *geneos.Component, args []string, params []string) (err error)
 	return
 }
 
-func psInstancePlain(c geneos.Instance, params []string) (err error) {
-	if instance.IsDisabled(c) {
+func psInstance(c geneos.Instance, params []string) (err error) {
+	row, err := newPsType(c, !psCmdJSON && !psCmdCSV, psCmdMetrics)
+	if err != nil {
 		return nil
 	}
+
+	psRowsMutex.Lock()
+	psRows = append(psRows, row)
+	psRowsMutex.Unlock()
+
+	return nil
+}
+
+// newPsType returns the process details of c, looking up the listening
+// ports only if ports is true and the resource usage only if metrics is
+// true as these can be slow. An error is returned if c is disabled or
+// not running.
+func newPsType(c geneos.Instance, ports, metrics bool) (row psType, err error) {
+	if instance.IsDisabled(c) {
+		return row, geneos.ErrDisabled
+	}
 	pid, uid, gid, mtime, err := instance.GetPIDInfo(c)
 	if err != nil {
-		return nil
+		return
 	}
 
 	var u *user.User
@@ -121,63 +213,196 @@ func psInstancePlain(c geneos.Instance, params []string) (err error) {
 		groupname = g.Name
 	}
 	base, underlying, _ := instance.Version(c)
-	ports := instance.Ports(c)
 
-	fmt.Fprintf(psTabWriter, "%s\t%s\t%s\t%d\t%v\t%s\t%s\t%s\t%s:%s\t%s\n", c.Type(), c.Name(), c.Host(), pid, ports, username, groupname, time.Unix(mtime, 0).Local().Format(time.RFC3339), base, underlying, c.Home())
+	var portlist []int
+	if ports {
+		portlist = instance.Ports(c)
+	}
 
+	row = psType{
+		Type:      c.Type().String(),
+		Name:      c.Name(),
+		Host:      c.Host().String(),
+		PID:       fmt.Sprint(pid),
+		Ports:     portlist,
+		User:      username,
+		Group:     groupname,
+		Starttime: time.Unix(mtime, 0).Local().Format(time.RFC3339),
+		Version:   fmt.Sprintf("%s:%s", base, underlying),
+		Home:      c.Home(),
+	}
+
+	if metrics {
+		// the process may have gone, keep the basic details
+		if _, stats, err := instance.GetProcessStats(c); err == nil {
+			row.Starttime = stats.Start.Local().Format(time.RFC3339)
+			row.CPU = stats.CPU
+			row.RSS = stats.RSS
+			row.VSZ = stats.VSZ
+			row.Threads = stats.Threads
+			row.OpenFiles = stats.OpenFiles
+			row.Uptime = int64(stats.Uptime.Seconds())
+		} else {
+			logDebug.Println(c, err)
+		}
+	}
+	return row, nil
+}
+
+// humanKiB returns a size in KiB in the largest whole unit, like 'ls -h'
+func humanKiB(kib int64) string {
+	size := float64(kib)
+	for _, unit := range []string{"K", "M", "G"} {
+		if size < 1024 {
+			return fmt.Sprintf("%.1f%s", size, unit)
+		}
+		size /= 1024
+	}
+	return fmt.Sprintf("%.1fT", size)
+}
+
+type psSocketType struct {
+	Type   string
+	Name   string
+	Host   string
+	PID    int
+	Proto  string
+	Local  string
+	Remote string
+	State  string
+	Inode  int
+}
+
+var psSocketRows []psSocketType
+
+func commandPSSockets(ct *geneos.Component, args []string, params []string) (err error) {
+	psSocketRows = nil
+	err = instance.ForAll(ct, psSocketsInstance, args, params)
+	sort.SliceStable(psSocketRows, func(i, j int) bool {
+		return lessInstance(psSocketRows[i].Type, psSocketRows[i].Name, psSocketRows[i].Host, psSocketRows[j].Type, psSocketRows[j].Name, psSocketRows[j].Host)
+	})
+
+	switch {
+	case psCmdJSON:
+		jsonEncoder = json.NewEncoder(outputWriter())
+		for _, r := range psSocketRows {
+			jsonEncoder.Encode(r)
+		}
+	case psCmdCSV:
+		csvWriter = csv.NewWriter(outputWriter())
+		csvWriter.Write([]string{"Type", "Name", "Host", "PID", "Proto", "Local", "Remote", "State", "Inode"})
+		for _, r := range psSocketRows {
+			csvWriter.Write([]string{r.Type, r.Name, r.Host, fmt.Sprint(r.PID), r.Proto, r.Local, r.Remote, r.State, fmt.Sprint(r.Inode)})
+		}
+		csvWriter.Flush()
+	default:
+		psTabWriter = tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
+		fmt.Fprintf(psTabWriter, "Type\tName\tHost\tPID\tProto\tLocal\tRemote\tState\n")
+		for _, r := range psSocketRows {
+			fmt.Fprintf(psTabWriter, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n", r.Type, r.Name, r.Host, r.PID, r.Proto, r.Local, r.Remote, r.State)
+		}
+		psTabWriter.Flush()
+	}
+	if err == os.ErrNotExist {
+		err = nil
+	}
 	return
 }
 
-func psInstanceCSV(c geneos.Instance, params []string) (err error) {
+func psSocketsInstance(c geneos.Instance, params []string) (err error) {
 	if instance.IsDisabled(c) {
 		return nil
 	}
-	pid, uid, gid, mtime, err := instance.GetPIDInfo(c)
+	pid, err := instance.GetPID(c)
 	if err != nil {
 		return nil
 	}
+	sockets, err := instance.Sockets(c)
+	if err != nil {
+		log.Println(c, "cannot read sockets:", err)
+		return nil
+	}
+	// listening sockets first, then by local port
+	sort.SliceStable(sockets, func(i, j int) bool {
+		if (sockets[i].State == "LISTEN") != (sockets[j].State == "LISTEN") {
+			return sockets[i].State == "LISTEN"
+		}
+		return sockets[i].LocalPort < sockets[j].LocalPort
+	})
 
-	var u *user.User
-	var g *user.Group
+	psRowsMutex.Lock()
+	defer psRowsMutex.Unlock()
+	for _, s := range sockets {
+		psSocketRows = append(psSocketRows, psSocketType{c.Type().String(), c.Name(), c.Host().String(), pid, s.Proto, s.Local(), s.Remote(), s.State, s.Inode})
+	}
+	return nil
+}
 
-	username := fmt.Sprint(uid)
-	groupname := fmt.Sprint(gid)
+type psVerifyType struct {
+	Type    string
+	Name    string
+	Host    string
+	State   string
+	PIDFile int
+	PID     int
+}
 
-	if u, err = user.LookupId(username); err == nil {
-		username = u.Username
+var psVerifyRows []psVerifyType
+
+func commandPSVerify(ct *geneos.Component, args []string, params []string) (err error) {
+	psVerifyRows = nil
+	err = instance.ForAll(ct, psVerifyInstance, args, params)
+	sort.Slice(psVerifyRows, func(i, j int) bool {
+		return lessInstance(psVerifyRows[i].Type, psVerifyRows[i].Name, psVerifyRows[i].Host, psVerifyRows[j].Type, psVerifyRows[j].Name, psVerifyRows[j].Host)
+	})
+
+	switch {
+	case psCmdJSON:
+		jsonEncoder = json.NewEncoder(outputWriter())
+		for _, r := range psVerifyRows {
+			jsonEncoder.Encode(r)
+		}
+	case psCmdCSV:
+		csvWriter = csv.NewWriter(outputWriter())
+		csvWriter.Write([]string{"Type", "Name", "Host", "State", "PIDFile", "PID"})
+		for _, r := range psVerifyRows {
+			csvWriter.Write([]string{r.Type, r.Name, r.Host, r.State, fmt.Sprint(r.PIDFile), fmt.Sprint(r.PID)})
+		}
+		csvWriter.Flush()
+	default:
+		psTabWriter = tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
+		fmt.Fprintf(psTabWriter, "Type\tName\tHost\tState\tPIDFile\tPID\n")
+		for _, r := range psVerifyRows {
+			fmt.Fprintf(psTabWriter, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Type, r.Name, r.Host, r.State, psPIDString(r.PIDFile), psPIDString(r.PID))
+		}
+		psTabWriter.Flush()
 	}
-	if g, err = user.LookupGroupId(groupname); err == nil {
-		groupname = g.Name
+	if err == os.ErrNotExist {
+		err = nil
 	}
-	base, underlying, _ := instance.Version(c)
-	csvWriter.Write([]string{c.Type().String(), c.Name(), c.Host().String(), fmt.Sprint(pid), username, groupname, time.Unix(mtime, 0).Local().Format(time.RFC3339), fmt.Sprintf("%s:%s", base, underlying), c.Home()})
-
 	return
 }
 
-func psInstanceJSON(c geneos.Instance, params []string) (err error) {
+func psVerifyInstance(c geneos.Instance, params []string) (err error) {
 	if instance.IsDisabled(c) {
 		return nil
 	}
-	pid, uid, gid, mtime, err := instance.GetPIDInfo(c)
+	state, filepid, pid, err := instance.VerifyPID(c)
 	if err != nil {
+		log.Println(c, err)
 		return nil
 	}
 
-	var u *user.User
-	var g *user.Group
-
-	username := fmt.Sprint(uid)
-	groupname := fmt.Sprint(gid)
+	psRowsMutex.Lock()
+	psVerifyRows = append(psVerifyRows, psVerifyType{c.Type().String(), c.Name(), c.Host().String(), state, filepid, pid})
+	psRowsMutex.Unlock()
+	return nil
+}
 
-	if u, err = user.LookupId(username); err == nil {
-		username = u.Username
-	}
-	if g, err = user.LookupGroupId(groupname); err == nil {
-		groupname = g.Name
+// psPIDString returns pid as a string or "-" if it is zero
+func psPIDString(pid int) string {
+	if pid == 0 {
+		return "-"
 	}
-	base, underlying, _ := instance.Version(c)
-	jsonEncoder.Encode(psType{c.Type().String(), c.Name(), c.Host().String(), fmt.Sprint(pid), username, groupname, time.Unix(mtime, 0).Local().Format(time.RFC3339), fmt.Sprintf("%s:%s", base, underlying), c.Home()})
-
-	return
+	return fmt.Sprint(pid)
 }
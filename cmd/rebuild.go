@@ -36,10 +36,13 @@ var rebuildCmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "true",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, rebuildInstance, args, params)
+		// serial as some rebuilds check the ports of other
+		// instances while others may be updating their config
+		return outputResults(instance.ForAllResults(ct, rebuildInstance, args, params, geneos.Parallel(1)))
 	},
 }
 
@@ -53,13 +56,22 @@ func init() {
 
 var rebuildCmdForce, rebuildCmdReload bool
 
-func rebuildInstance(c geneos.Instance, params []string) (err error) {
-	if err = c.Rebuild(rebuildCmdForce); err != nil {
-		return
+func rebuildInstance(c geneos.Instance, params []string) (result instance.Result) {
+	return rebuild(c, rebuildCmdForce, rebuildCmdReload, params)
+}
+
+// rebuild the configuration files for c and, if reload is true, signal
+// it to reload them
+func rebuild(c geneos.Instance, force, reload bool, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "rebuild")
+	if err := instance.Rebuild(c, force); err != nil {
+		return result.Fail(err)
 	}
 	logDebug.Println(c, "configuration rebuilt (if supported)")
-	if !rebuildCmdReload {
+	if !reload {
 		return
 	}
-	return reloadInstance(c, params)
+	reloaded := reloadInstance(c, params)
+	reloaded.Action = result.Action
+	return reloaded
 }
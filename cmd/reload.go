@@ -40,7 +40,7 @@ depending on the component TYPE.`,
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, reloadInstance, args, params)
+		return outputResults(instance.ForAllResults(ct, reloadInstance, args, params))
 	},
 }
 
@@ -49,6 +49,10 @@ func init() {
 	reloadCmd.Flags().SortFlags = false
 }
 
-func reloadInstance(c geneos.Instance, params []string) (err error) {
-	return c.Reload(params)
+func reloadInstance(c geneos.Instance, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "reload")
+	if err := c.Reload(params); err != nil {
+		return result.Fail(err)
+	}
+	return result.Set(instance.OK, "reloaded")
 }
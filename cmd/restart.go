@@ -22,8 +22,8 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"errors"
-	"os"
+	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 	"wonderland.org/geneos/internal/geneos"
@@ -32,17 +32,27 @@ import (
 
 // restartCmd represents the restart command
 var restartCmd = &cobra.Command{
-	Use:   "restart [-a] [-K] [-l] [TYPE] [NAME...]",
+	Use:   "restart [-a] [-K] [-t TIMEOUT] [-w TIMEOUT] [-R [-b N] [-p PAUSE]] [-l] [--selector SELECTOR] [TYPE] [NAME...]",
 	Short: "Restart instances",
 	Long: `Restart the matching instances. This is identical to running 'geneos
 stop' followed by 'geneos start' except if the -a flag is given then
 all matching instances are started regardless of whether they were
 stopped by the command. The command also accepts the same flags as
-both start and stop.`,
+both start and stop, so -K stops the instances with an immediate
+SIGKILL, as for 'geneos stop -K', before they are started again.
+
+With -R (--rolling) the instances are restarted in batches of N (default
+1), in the order they are matched. Each batch must be ready, which is
+listening on its port within the -w timeout (default 60s), and then
+still running with the same PID after PAUSE (default 10s) before the
+next batch is restarted. There is no pause after a batch in which
+nothing was restarted or after the last batch. The roll stops at the first batch with a
+failure and the remaining instances are skipped.`,
 	SilenceUsage:          true,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "true",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
@@ -55,14 +65,26 @@ func init() {
 
 	restartCmd.Flags().BoolVarP(&restartCmdAll, "all", "a", false, "Start all matcheing instances, not just those already running")
 	restartCmd.Flags().BoolVarP(&restartCmdKill, "kill", "K", false, "Force stop by sending an immediate SIGKILL")
+	restartCmd.Flags().DurationVarP(&restartCmdTimeout, "timeout", "t", 0, "Wait `TIMEOUT` for instances to stop before a SIGKILL, instead of the stop policy")
+	restartCmd.Flags().DurationVarP(&restartCmdWait, "wait", "w", 0, "Wait up to `TIMEOUT` for instances to listen on their ports")
+	restartCmd.Flags().BoolVarP(&restartCmdRolling, "rolling", "R", false, "Restart instances in batches, waiting for each to be ready")
+	restartCmd.Flags().IntVarP(&restartCmdBatch, "batch", "b", 1, "Number of instances in each batch of a rolling restart")
+	restartCmd.Flags().DurationVarP(&restartCmdPause, "pause", "p", 10*time.Second, "How long each batch must stay up before the next, for a rolling restart")
 	restartCmd.Flags().BoolVarP(&restartCmdLogs, "log", "l", false, "Run 'logs -f' after starting instance(s)")
 	restartCmd.Flags().SortFlags = false
 }
 
-var restartCmdAll, restartCmdKill, restartCmdLogs bool
+var restartCmdAll, restartCmdKill, restartCmdLogs, restartCmdRolling bool
+var restartCmdTimeout, restartCmdWait, restartCmdPause time.Duration
+var restartCmdBatch int
 
 func commandRestart(ct *geneos.Component, args []string, params []string) (err error) {
-	if err = instance.ForAll(ct, restartInstance, args, params); err != nil {
+	if restartCmdRolling {
+		err = outputResults(rollingRestart(ct, args, params))
+	} else {
+		err = outputResults(instance.ForAllResults(ct, restartInstance, args, params))
+	}
+	if err != nil {
 		logDebug.Println(err)
 		return
 	}
@@ -74,10 +96,87 @@ func commandRestart(ct *geneos.Component, args []string, params []string) (err e
 	return
 }
 
-func restartInstance(c geneos.Instance, params []string) (err error) {
-	err = instance.Stop(c, false)
-	if err == nil || (errors.Is(err, os.ErrProcessDone) && restartCmdAll) {
-		return instance.Start(c)
+func restartInstance(c geneos.Instance, params []string) (result instance.Result) {
+	return restart(c, restartCmdAll, restartCmdKill, restartCmdTimeout, restartCmdWait)
+}
+
+// restart stops and then starts c. If all is true then c is started even
+// if it was not running and if kill is true it is stopped with a SIGKILL.
+// A non-zero timeout overrides the stop policy timeout and a non-zero
+// wait waits for c to be ready after starting, see instance.StartWait.
+func restart(c geneos.Instance, all, kill bool, timeout, wait time.Duration) (result instance.Result) {
+	result = instance.StopWait(c, kill, timeout)
+	if result.Outcome == instance.OK || (result.Outcome == instance.Unchanged && all) {
+		result = instance.StartWait(c, wait)
 	}
+	result.Action = "restart"
 	return
 }
+
+// the default readiness timeout for a rolling restart without -w
+const rollingWait = 60 * time.Second
+
+// rollingRestart restarts the matching instances in batches of
+// restartCmdBatch, waiting for each batch to be ready and then stable
+// for restartCmdPause before the next. After the first batch with a
+// failure the remaining instances are skipped.
+func rollingRestart(ct *geneos.Component, args []string, params []string) (results instance.Results, err error) {
+	cs, err := instance.MatchArgs(ct, args, params)
+	if err != nil {
+		return
+	}
+	batch := restartCmdBatch
+	if batch < 1 {
+		batch = 1
+	}
+	wait := restartCmdWait
+	if wait == 0 {
+		wait = rollingWait
+	}
+
+	for start := 0; start < len(cs); start += batch {
+		end := start + batch
+		if end > len(cs) {
+			end = len(cs)
+		}
+		stage := cs[start:end]
+		batchResults := make(instance.Results, len(stage))
+		instance.Run(stage, func(i int, c geneos.Instance) error {
+			batchResults[i] = restart(c, restartCmdAll, restartCmdKill, restartCmdTimeout, wait)
+			return nil
+		}, 0)
+
+		// nothing is restarted in a dry run. Only pause if something in
+		// the batch was restarted and there is another batch to follow,
+		// the last batch is checked as soon as it is ready
+		if batchResults.Err() == nil && !dryRun {
+			restarted := false
+			for _, r := range batchResults {
+				if r.Outcome == instance.OK {
+					restarted = true
+				}
+			}
+			if restarted && end < len(cs) {
+				time.Sleep(restartCmdPause)
+			}
+			// stable means still running as the process that was started
+			for i, r := range batchResults {
+				if r.Outcome != instance.OK {
+					continue
+				}
+				if pid, err := instance.GetPID(r.Instance); err != nil || pid != r.PID {
+					batchResults[i] = r.Fail(fmt.Errorf("not running with PID %d after %v", r.PID, restartCmdPause))
+				}
+			}
+		}
+		results = append(results, batchResults...)
+
+		if batchResults.Err() != nil {
+			for _, c := range cs[end:] {
+				results = append(results, instance.NewResult(c, "restart").Set(instance.Skipped, "rolling restart stopped after a failure"))
+			}
+			break
+		}
+	}
+	return results, results.Err()
+}
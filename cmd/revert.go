@@ -42,7 +42,7 @@ never changed.`,
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, revertInstance, args, params)
+		return outputResults(instance.ForAllResults(ct, revertInstance, args, params))
 	},
 }
 
@@ -51,24 +51,25 @@ func init() {
 	revertCmd.Flags().SortFlags = false
 }
 
-func revertInstance(c geneos.Instance, params []string) (err error) {
+func revertInstance(c geneos.Instance, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "revert")
 	// if *.rc file exists, remove rc.orig+JSON, continue
 	if _, err := c.Host().Stat(instance.ConfigPathWithExt(c, "rc")); err == nil {
 		// ignore errors
 		if c.Host().Remove(instance.ConfigPathWithExt(c, "rc.orig")) == nil || c.Host().Remove(instance.ConfigPathWithExt(c, "json")) == nil {
 			logDebug.Println(c, "removed extra config file(s)")
 		}
-		return err
+		return result.Set(instance.Unchanged, "")
 	}
 
-	if err = c.Host().Rename(instance.ConfigPathWithExt(c, "rc.orig"), instance.ConfigPathWithExt(c, "rc")); err != nil {
-		return
+	if err := c.Host().Rename(instance.ConfigPathWithExt(c, "rc.orig"), instance.ConfigPathWithExt(c, "rc")); err != nil {
+		return result.Fail(err)
 	}
 
-	if err = c.Host().Remove(instance.ConfigPathWithExt(c, "json")); err != nil {
-		return
+	if err := c.Host().Remove(instance.ConfigPathWithExt(c, "json")); err != nil {
+		return result.Fail(err)
 	}
 
 	logDebug.Println(c, "reverted to RC config")
-	return nil
+	return
 }
@@ -100,7 +100,18 @@ You can fix this by doing one of the following:
 			}
 		}
 
-		parseArgs(cmd, args)
+		switch outputFormat {
+		case "text", "json", "csv":
+		default:
+			return fmt.Errorf("unknown output format %q, must be one of text, json or csv", outputFormat)
+		}
+
+		if err = parseArgs(cmd, args); err != nil {
+			return
+		}
+		if dryRun {
+			err = setDryRun(cmd)
+		}
 		return
 	},
 }
@@ -108,6 +119,7 @@ You can fix this by doing one of the following:
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	addSelectorFlags(rootCmd)
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
@@ -115,10 +127,12 @@ func Execute() {
 }
 
 func RootCmd() *cobra.Command {
+	addSelectorFlags(rootCmd)
 	return rootCmd
 }
 
 var debug, quiet bool
+var parallel int
 
 func init() {
 	cobra.OnInitialize(initConfig)
@@ -131,6 +145,9 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "enable extra debug output")
 	rootCmd.PersistentFlags().MarkHidden("debug")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "quiet mode")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text", "output format for command results, one of text, json or csv")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "show the instances that would be acted on, without changing anything")
+	rootCmd.PersistentFlags().IntVar(&parallel, "parallel", 0, "maximum number of instances to act on concurrently (default from \"parallel\" setting)")
 
 	rootCmd.PersistentFlags().StringVarP(&username, "username", "u", "", "username for downloads")
 	// rootCmd.PersistentFlags().BoolVarP(&passwordPrompt, "password", "p", false, "prompt for a password, only valid for downloads and in conjunction with -u")
@@ -152,6 +169,11 @@ func initConfig() {
 		logger.EnableDebugLog()
 	}
 
+	// keep stdout for structured output
+	if !quiet && outputFormat != "text" {
+		log.SetOutput(os.Stderr)
+	}
+
 	viper.SetEnvPrefix("ITRS")
 	viper.BindEnv("geneos", "ITRS_HOME")
 
@@ -184,6 +206,10 @@ func initConfig() {
 		viper.Set("itrshome", nil)
 	}
 
+	if parallel > 0 {
+		viper.Set("parallel", parallel)
+	}
+
 	if username != "" {
 		viper.Set("download.username", username)
 	}
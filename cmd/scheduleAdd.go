@@ -0,0 +1,101 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"wonderland.org/geneos/internal/geneos"
+)
+
+// scheduleAddCmd represents the schedule add command
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add [-F] NAME CRON ACTION [--] [ARGS...]",
+	Short: "Add a scheduled action",
+	Long: `Add a schedule called NAME to run ACTION at the times given by CRON.
+ACTION is one of start, stop, restart, clean or update and ARGS are
+passed to it, so these can be a TYPE and NAMEs and any flags, which
+must follow a "--" so that they are not taken as flags for this
+command. CRON is in the five field format of crontab(5) - minute, hour,
+day of month, month and day of week - and must be quoted, or one of
+@yearly, @monthly, @weekly, @daily or @hourly. Times are local.
+
+An existing schedule called NAME is only replaced if -F is given.
+
+For example:
+
+  geneos schedule add nightly "0 2 * * *" restart gateway
+  geneos schedule add purge "30 3 * * sun" clean -- -F netprobe`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "false",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return commandScheduleAdd(scheduleRawArgs(cmd))
+	},
+}
+
+func init() {
+	scheduleCmd.AddCommand(scheduleAddCmd)
+	scheduleAddCmd.Flags().BoolVarP(&scheduleAddCmdForce, "force", "F", false, "Replace an existing schedule with the same name")
+	scheduleAddCmd.Flags().SortFlags = false
+}
+
+var scheduleAddCmdForce bool
+
+// scheduleRawArgs returns the command line arguments of cmd as given,
+// undoing the splitting of TYPE and NAME=VALUE parameters done for all
+// commands. Parameters are moved to the end.
+func scheduleRawArgs(cmd *cobra.Command) (args []string) {
+	ct, args, params := cmdArgsParams(cmd)
+	if ct != nil {
+		args = append([]string{cmd.Annotations["ct"]}, args...)
+	}
+	return append(args, params...)
+}
+
+func commandScheduleAdd(args []string) (err error) {
+	if len(args) < 3 {
+		return fmt.Errorf("NAME, CRON and ACTION are required: %w", geneos.ErrInvalidArgs)
+	}
+	s := geneos.Schedule{Name: args[0], Cron: args[1], Action: args[2], Args: args[3:]}
+	if _, err = s.Validate(); err != nil {
+		return
+	}
+
+	schedules, err := geneos.ReadSchedules()
+	if err != nil {
+		return
+	}
+	for i, old := range schedules {
+		if old.Name == s.Name {
+			if !scheduleAddCmdForce {
+				return fmt.Errorf("schedule %q already exists, use -F to replace it", s.Name)
+			}
+			schedules[i] = s
+			return geneos.WriteSchedules(schedules)
+		}
+	}
+	return geneos.WriteSchedules(append(schedules, s))
+}
@@ -0,0 +1,90 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"wonderland.org/geneos/internal/geneos"
+)
+
+// scheduleDeleteCmd represents the schedule delete command
+var scheduleDeleteCmd = &cobra.Command{
+	Use:                   "delete NAME...",
+	Short:                 "Delete scheduled actions",
+	Long:                  `Delete the named schedules.`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "false",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return commandScheduleDelete(scheduleRawArgs(cmd))
+	},
+}
+
+func init() {
+	scheduleCmd.AddCommand(scheduleDeleteCmd)
+	scheduleDeleteCmd.Flags().SortFlags = false
+}
+
+func commandScheduleDelete(names []string) (err error) {
+	if len(names) == 0 {
+		return fmt.Errorf("no schedule names given: %w", geneos.ErrInvalidArgs)
+	}
+	schedules, err := geneos.ReadSchedules()
+	if err != nil {
+		return
+	}
+
+	var kept []geneos.Schedule
+	deleted := make(map[string]bool)
+SCHEDULES:
+	for _, s := range schedules {
+		for _, n := range names {
+			if s.Name == n {
+				deleted[n] = true
+				continue SCHEDULES
+			}
+		}
+		kept = append(kept, s)
+	}
+	for _, n := range names {
+		if !deleted[n] {
+			log.Printf("schedule %q not found", n)
+		}
+	}
+	if len(deleted) == 0 {
+		return os.ErrNotExist
+	}
+	if err = geneos.WriteSchedules(kept); err != nil {
+		return
+	}
+	for _, n := range names {
+		if deleted[n] {
+			log.Printf("schedule %q deleted", n)
+		}
+	}
+	return
+}
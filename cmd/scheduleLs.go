@@ -0,0 +1,102 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"wonderland.org/geneos/internal/geneos"
+)
+
+// scheduleLsCmd represents the schedule ls command
+var scheduleLsCmd = &cobra.Command{
+	Use:                   "ls",
+	Short:                 "List scheduled actions",
+	Long:                  `List the scheduled actions and the next time each will run.`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "false",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return commandScheduleLs()
+	},
+}
+
+func init() {
+	scheduleCmd.AddCommand(scheduleLsCmd)
+	scheduleLsCmd.Flags().SortFlags = false
+}
+
+type scheduleLsType struct {
+	Name   string
+	Cron   string
+	Action string
+	Args   []string
+	Next   string
+}
+
+func commandScheduleLs() (err error) {
+	schedules, err := geneos.ReadSchedules()
+	if err != nil {
+		return
+	}
+	var rows []scheduleLsType
+	for _, s := range schedules {
+		row := scheduleLsType{Name: s.Name, Cron: s.Cron, Action: s.Action, Args: s.Args}
+		if c, err := s.Validate(); err != nil {
+			row.Next = err.Error()
+		} else if next := c.Next(time.Now()); !next.IsZero() {
+			row.Next = next.Format(time.RFC3339)
+		}
+		rows = append(rows, row)
+	}
+
+	switch outputFormat {
+	case "json":
+		jsonEncoder = json.NewEncoder(outputWriter())
+		for _, r := range rows {
+			jsonEncoder.Encode(r)
+		}
+	case "csv":
+		csvWriter = csv.NewWriter(outputWriter())
+		csvWriter.Write([]string{"Name", "Cron", "Action", "Args", "Next"})
+		for _, r := range rows {
+			csvWriter.Write([]string{r.Name, r.Cron, r.Action, strings.Join(r.Args, " "), r.Next})
+		}
+		csvWriter.Flush()
+	default:
+		w := tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "Name\tCron\tAction\tArgs\tNext\n")
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.Cron, r.Action, strings.Join(r.Args, " "), r.Next)
+		}
+		w.Flush()
+	}
+	return
+}
@@ -0,0 +1,133 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"wonderland.org/geneos/internal/geneos"
+)
+
+// scheduleRunCmd represents the schedule run command
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run scheduled actions",
+	Long: `Run in the foreground and run each scheduled action at the times in
+its schedule. Each action is run as a separate geneos command and its
+output is logged with the schedule name. The schedules are re-read every
+minute so changes made with 'schedule add' and 'schedule delete' are
+seen without restarting. An action is not run again while the previous
+run of the same schedule is still going.`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "false",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		runSchedules()
+		return nil
+	},
+}
+
+func init() {
+	scheduleCmd.AddCommand(scheduleRunCmd)
+	scheduleRunCmd.Flags().SortFlags = false
+}
+
+// runSchedules runs scheduled actions at the start of each minute and
+// never returns
+func runSchedules() {
+	var running sync.Map
+	log.Println("running scheduled actions")
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		now = time.Now()
+
+		schedules, err := geneos.ReadSchedules()
+		if err != nil {
+			logError.Println("cannot read schedules:", err)
+			continue
+		}
+		for _, s := range schedules {
+			c, err := s.Validate()
+			if err != nil {
+				logError.Printf("schedule %q: %s", s.Name, err)
+				continue
+			}
+			if !c.Matches(now) {
+				continue
+			}
+			if _, busy := running.LoadOrStore(s.Name, true); busy {
+				log.Printf("schedule %q: previous run still going, skipped", s.Name)
+				continue
+			}
+			go func(s geneos.Schedule) {
+				defer running.Delete(s.Name)
+				runSchedule(s)
+			}(s)
+		}
+	}
+}
+
+// runSchedule runs the action of s as a geneos command, logging the
+// output
+func runSchedule(s geneos.Schedule) {
+	self, err := os.Executable()
+	if err != nil {
+		logError.Printf("schedule %q: %s", s.Name, err)
+		return
+	}
+	var args []string
+	if cfgFile != "" {
+		args = append(args, "--config", cfgFile)
+	}
+	args = append(args, s.Action)
+	args = append(args, s.Args...)
+
+	log.Printf("schedule %q: running %s %v", s.Name, s.Action, s.Args)
+	cmd := exec.Command(self, args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		logError.Printf("schedule %q: %s", s.Name, err)
+		return
+	}
+	cmd.Stderr = cmd.Stdout
+	if err = cmd.Start(); err != nil {
+		logError.Printf("schedule %q: %s", s.Name, err)
+		return
+	}
+	lines := bufio.NewScanner(out)
+	for lines.Scan() {
+		log.Printf("schedule %q: %s", s.Name, lines.Text())
+	}
+	if err = cmd.Wait(); err != nil {
+		logError.Printf("schedule %q: %s %v failed: %s", s.Name, s.Action, s.Args, err)
+		return
+	}
+	log.Printf("schedule %q: %s completed", s.Name, s.Action)
+}
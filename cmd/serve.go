@@ -0,0 +1,622 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+	"wonderland.org/geneos/internal/instance"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve [-l ADDR] [-T FILE] [--insecure] [--noweb] [--supervise] [--schedule]",
+	Short: "Run a REST API server",
+	Long: `Run a long-lived server providing a versioned JSON REST API to list
+and control instances. Requests must include the header
+"Authorization: Bearer TOKEN" where TOKEN is the contents of the token
+file, which is created with a random token if it does not exist.
+
+The server uses HTTPS with a certificate signed by the signing
+certificate created by 'geneos tls init'. The server certificate is
+created as "tls/serve.pem" on first use and can be replaced. Use
+--insecure to serve plain HTTP.
+
+All endpoints are under /api/v1/ and accept the query parameters
+"type" for the component type and "name", which may be repeated, for
+instance names in the form NAME or NAME@HOST. Without these all
+instances are selected.
+
+  GET  /api/v1/hosts      list remote hosts, like 'ls host'
+  GET  /api/v1/instances  list instances, like 'ls'
+  GET  /api/v1/ps         list running instances, like 'ps', with
+                          "metrics=true" for resource usage
+  GET  /api/v1/show       instance configurations, like 'show'
+  GET  /api/v1/logs       the last "lines" (default 100, at most 10000)
+                          lines of logs
+  GET  /api/v1/tls        instance certificates, like 'tls ls -l'
+  GET  /api/v1/restarts   restart histories, like 'supervise -H'
+  POST /api/v1/start      start instances, "wait=DURATION" as for the
+                          start command
+  POST /api/v1/stop       stop instances, "kill=true" to force and
+                          "timeout=DURATION" as for the stop command
+  POST /api/v1/restart    restart instances, "all=true", "kill=true",
+                          "timeout=DURATION" and "wait=DURATION" as for
+                          the restart command
+  POST /api/v1/set        set the KEY/VALUE pairs in the JSON object in
+                          the request body
+  POST /api/v1/unset      unset the keys in the JSON array in the
+                          request body
+  POST /api/v1/rebuild    rebuild configuration files, "force=true" and
+                          "reload=true" as for the rebuild command
+
+Actions return a list of results in the same form as '--output json'.
+
+Unless --noweb is given a web dashboard is also served at the top
+level URL. The dashboard uses the REST API and asks for the token.
+
+With --supervise the server also restarts instances that stop
+unexpectedly, see 'geneos help supervise' for details and the
+settings.
+
+With --schedule the server also runs scheduled actions, as for
+'geneos schedule run'.`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "false",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return commandServe()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVarP(&serveCmdListen, "listen", "l", ":7443", "Listen on this address")
+	serveCmd.Flags().StringVarP(&serveCmdTokenFile, "tokenfile", "T", "", "Path to the API token file (default \"${geneos}/tls/serve.token\")")
+	serveCmd.Flags().BoolVar(&serveCmdInsecure, "insecure", false, "Serve plain HTTP instead of HTTPS")
+	serveCmd.Flags().BoolVar(&serveCmdNoWeb, "noweb", false, "Do not serve the web dashboard")
+	serveCmd.Flags().DurationVar(&serveCmdRetry, "retry", 30*time.Second, "Retry connecting to a failed remote host after this interval")
+	serveCmd.Flags().BoolVar(&serveCmdSupervise, "supervise", false, "Also restart instances that stop unexpectedly, as for the supervise command")
+	superviseFlags(serveCmd.Flags())
+	serveCmd.Flags().BoolVar(&serveCmdSchedule, "schedule", false, "Also run scheduled actions, as for the schedule run command")
+	serveCmd.Flags().SortFlags = false
+}
+
+var serveCmdListen, serveCmdTokenFile string
+var serveCmdInsecure, serveCmdNoWeb, serveCmdSupervise, serveCmdSchedule bool
+var serveCmdRetry time.Duration
+
+// serveMutex serialises requests as the instance configurations are
+// shared and not safe for concurrent changes. Each request may still act
+// on many instances concurrently.
+var serveMutex sync.Mutex
+
+func commandServe() (err error) {
+	host.RetryInterval = serveCmdRetry
+
+	token, err := serveToken(serveCmdTokenFile)
+	if err != nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+	serveAPI(mux, token)
+	if !serveCmdNoWeb {
+		if err = serveWeb(mux); err != nil {
+			return
+		}
+	}
+
+	if serveCmdSupervise {
+		go supervise(nil, nil, nil, &serveMutex)
+	}
+	if serveCmdSchedule {
+		go runSchedules()
+	}
+
+	srv := &http.Server{
+		Addr:              serveCmdListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if serveCmdInsecure {
+		log.Printf("serving on http://%s", serveCmdListen)
+		return srv.ListenAndServe()
+	}
+
+	certfile, keyfile, err := serveCertificate()
+	if err != nil {
+		return
+	}
+	srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	log.Printf("serving on https://%s", serveCmdListen)
+	return srv.ListenAndServeTLS(certfile, keyfile)
+}
+
+// serveToken returns the API token stored in path, creating a new
+// random token if the file does not exist
+func serveToken(path string) (token string, err error) {
+	if path == "" {
+		path = filepath.Join(host.Geneos(), "tls", "serve.token")
+	}
+	b, err := host.LOCAL.ReadFile(path)
+	if err == nil {
+		if token = strings.TrimSpace(string(b)); token == "" {
+			err = fmt.Errorf("token file %q is empty", path)
+		}
+		return
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	r := make([]byte, 32)
+	if _, err = rand.Read(r); err != nil {
+		return
+	}
+	token = hex.EncodeToString(r)
+	if err = host.LOCAL.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return
+	}
+	if err = host.LOCAL.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return
+	}
+	log.Println("new API token written to", path)
+	return
+}
+
+// serveCertificate returns the paths to the server certificate and key,
+// creating them from the signing certificate if they do not exist or the
+// certificate has expired
+func serveCertificate() (certfile, keyfile string, err error) {
+	tlsDir := filepath.Join(host.Geneos(), "tls")
+	certfile = filepath.Join(tlsDir, "serve.pem")
+	keyfile = filepath.Join(tlsDir, "serve.key")
+
+	if cert, err := host.LOCAL.ReadCert(certfile); err == nil && time.Now().Before(cert.NotAfter) {
+		if _, err = host.LOCAL.ReadKey(keyfile); err == nil {
+			return certfile, keyfile, nil
+		}
+	}
+
+	intrCert, err := instance.ReadSigningCert()
+	if err != nil {
+		err = fmt.Errorf("cannot read signing certificate, run 'geneos tls init' or use --insecure: %w", err)
+		return
+	}
+	intrKey, err := host.LOCAL.ReadKey(filepath.Join(tlsDir, geneos.SigningCertFile+".key"))
+	if err != nil {
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	serial, err := rand.Prime(rand.Reader, 64)
+	if err != nil {
+		return
+	}
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: fmt.Sprintf("geneos serve %s", hostname),
+		},
+		NotBefore:      time.Now().Add(-60 * time.Second),
+		NotAfter:       time.Now().AddDate(1, 0, 0),
+		KeyUsage:       x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		MaxPathLenZero: true,
+		DNSNames:       []string{hostname, "localhost"},
+		IPAddresses:    []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+	}
+
+	cert, key, err := instance.CreateCertKey(&template, intrCert, intrKey, nil)
+	if err != nil {
+		return
+	}
+	// include the signing certificate so clients only need the root
+	if err = host.LOCAL.WriteCerts(certfile, cert, intrCert); err != nil {
+		return
+	}
+	if err = host.LOCAL.WriteKey(keyfile, key); err != nil {
+		return
+	}
+	log.Println("new server certificate written to", certfile)
+	return
+}
+
+//go:embed web
+var webFiles embed.FS
+
+// serveWeb adds the embedded dashboard. The static files need no
+// authentication, the dashboard sends the token with each API request.
+func serveWeb(mux *http.ServeMux) (err error) {
+	files, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		return
+	}
+	fileServer := http.FileServer(http.FS(files))
+	mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "default-src 'self'")
+		w.Header().Set("X-Frame-Options", "DENY")
+		fileServer.ServeHTTP(w, r)
+	}))
+	return
+}
+
+// apiFunc is the signature of REST API handlers. The returned value is
+// encoded as JSON.
+type apiFunc func(r *http.Request, ct *geneos.Component, names []string) (interface{}, error)
+
+func serveAPI(mux *http.ServeMux, token string) {
+	routes := []struct {
+		method, path string
+		fn           apiFunc
+	}{
+		{http.MethodGet, "hosts", apiHosts},
+		{http.MethodGet, "instances", apiInstances},
+		{http.MethodGet, "ps", apiPS},
+		{http.MethodGet, "show", apiShow},
+		{http.MethodGet, "logs", apiLogs},
+		{http.MethodGet, "tls", apiTLS},
+		{http.MethodGet, "restarts", apiRestarts},
+		{http.MethodPost, "start", apiStart},
+		{http.MethodPost, "stop", apiStop},
+		{http.MethodPost, "restart", apiRestart},
+		{http.MethodPost, "set", apiSet},
+		{http.MethodPost, "unset", apiUnset},
+		{http.MethodPost, "rebuild", apiRebuild},
+	}
+	for _, route := range routes {
+		mux.Handle("/api/v1/"+route.path, apiHandler(token, route.method, route.fn))
+	}
+}
+
+// apiHandler checks the request method and token, calls fn with the
+// selected component type and instance names and writes the result
+func apiHandler(token, method string, fn apiFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) != 1 {
+			apiError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			apiError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		q := r.URL.Query()
+		var ct *geneos.Component
+		if t := q.Get("type"); t != "" {
+			if ct = geneos.ParseComponentName(t); ct == nil {
+				apiError(w, http.StatusBadRequest, fmt.Errorf("unknown type %q", t))
+				return
+			}
+		}
+
+		serveMutex.Lock()
+		defer serveMutex.Unlock()
+		serveRefresh()
+
+		logDebug.Println(r.RemoteAddr, r.Method, r.URL)
+		v, err := fn(r, ct, q["name"])
+		switch {
+		case err == nil:
+		case errors.Is(err, os.ErrNotExist):
+			apiError(w, http.StatusNotFound, err)
+			return
+		case errors.Is(err, geneos.ErrInvalidArgs):
+			apiError(w, http.StatusBadRequest, err)
+			return
+		default:
+			apiError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	})
+}
+
+func apiError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// serveRefresh drops all loaded instances so that changes made outside
+// the server, e.g. by other geneos commands, are seen
+func serveRefresh() {
+	for _, c := range instance.GetAll(host.ALL, nil) {
+		c.Unload()
+	}
+}
+
+// apiCollect calls fn for each matching instance and returns the
+// non-nil values sorted in the same order as ls
+func apiCollect(ct *geneos.Component, names []string, fn func(geneos.Instance) interface{}) (rows []interface{}, err error) {
+	type row struct {
+		c geneos.Instance
+		v interface{}
+	}
+	var all []row
+	var mutex sync.Mutex
+
+	err = instance.ForAll(ct, func(c geneos.Instance, _ []string) error {
+		if v := fn(c); v != nil {
+			mutex.Lock()
+			all = append(all, row{c, v})
+			mutex.Unlock()
+		}
+		return nil
+	}, names, nil)
+	if err != nil {
+		return
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i].c, all[j].c
+		return lessInstance(a.Type().String(), a.Name(), a.Host().String(), b.Type().String(), b.Name(), b.Host().String())
+	})
+	rows = []interface{}{}
+	for _, r := range all {
+		rows = append(rows, r.v)
+	}
+	return
+}
+
+func apiHosts(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	rows := []lsTypeHosts{}
+	for _, h := range host.AllHosts() {
+		if h == host.LOCAL {
+			continue
+		}
+		rows = append(rows, lsTypeHosts{h.String(), h.GetString("username"), h.GetString("hostname"), h.GetInt64("port"), h.GetString("geneos"), h.GetString("proxyjump")})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	return rows, nil
+}
+
+func apiInstances(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	return apiCollect(ct, names, func(c geneos.Instance) interface{} {
+		return newLsType(c)
+	})
+}
+
+func apiPS(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	metrics := r.URL.Query().Get("metrics") == "true"
+	return apiCollect(ct, names, func(c geneos.Instance) interface{} {
+		row, err := newPsType(c, false, metrics)
+		if err != nil {
+			return nil
+		}
+		return row
+	})
+}
+
+func apiShow(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	return apiCollect(ct, names, func(c geneos.Instance) interface{} {
+		buffer, err := showConfig(c)
+		if err != nil {
+			return nil
+		}
+		return json.RawMessage(buffer)
+	})
+}
+
+type apiLogType struct {
+	Type    string
+	Name    string
+	Host    string
+	Logfile string
+	Lines   []string
+	Error   string `json:",omitempty"`
+}
+
+// apiMaxLines limits the lines of each log returned by apiLogs, as the
+// whole response is built in memory
+const apiMaxLines = 10000
+
+func apiLogs(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	lines := 100
+	if l := r.URL.Query().Get("lines"); l != "" {
+		var err error
+		if lines, err = strconv.Atoi(l); err != nil || lines < 0 {
+			return nil, fmt.Errorf("invalid lines %q: %w", l, geneos.ErrInvalidArgs)
+		}
+		if lines > apiMaxLines {
+			lines = apiMaxLines
+		}
+	}
+	return apiCollect(ct, names, func(c geneos.Instance) interface{} {
+		logfile := instance.LogFile(c)
+		row := apiLogType{Type: c.Type().String(), Name: c.Name(), Host: c.Host().String(), Logfile: logfile, Lines: []string{}}
+		text, err := apiTail(c, logfile, lines)
+		if err != nil {
+			row.Error = err.Error()
+		} else if text != "" {
+			row.Lines = strings.Split(text, "\n")
+		}
+		return row
+	})
+}
+
+func apiTail(c geneos.Instance, logfile string, lines int) (text string, err error) {
+	st, err := c.Host().Stat(logfile)
+	if err != nil {
+		return
+	}
+	f, err := c.Host().Open(logfile)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	text, err = tailLines(f, st.St.Size(), lines)
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return
+}
+
+func apiTLS(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	return apiCollect(ct, names, func(c geneos.Instance) interface{} {
+		row, err := newCertRow(c)
+		if err != nil {
+			return nil
+		}
+		return newLsCertLongType(row)
+	})
+}
+
+func apiRestarts(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	rows, err := superviseHistory(ct, names, nil)
+	if rows == nil {
+		rows = []superviseHistoryType{}
+	}
+	return rows, err
+}
+
+// apiResults runs fn for each matching instance and returns the results
+// as they would be output with '--output json'. Failures are reported
+// in the results and not as an error.
+func apiResults(ct *geneos.Component, names []string, fn func(geneos.Instance, []string) instance.Result, params []string, options ...geneos.GeneosOptions) (interface{}, error) {
+	results, err := instance.ForAllResults(ct, fn, names, params, options...)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	rows := []resultType{}
+	for _, r := range results {
+		rows = append(rows, newResultType(r))
+	}
+	return rows, nil
+}
+
+func apiStart(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	wait, err := apiDuration(r, "wait")
+	if err != nil {
+		return nil, err
+	}
+	return apiResults(ct, names, func(c geneos.Instance, _ []string) instance.Result {
+		return instance.StartWait(c, wait)
+	}, nil)
+}
+
+func apiStop(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	kill := r.URL.Query().Get("kill") == "true"
+	timeout, err := apiDuration(r, "timeout")
+	if err != nil {
+		return nil, err
+	}
+	return apiResults(ct, names, func(c geneos.Instance, _ []string) instance.Result {
+		return instance.StopWait(c, kill, timeout)
+	}, nil)
+}
+
+// apiDuration returns the query parameter name as a duration, zero if
+// not given
+func apiDuration(r *http.Request, name string) (d time.Duration, err error) {
+	if v := r.URL.Query().Get(name); v != "" {
+		if d, err = time.ParseDuration(v); err != nil || d < 0 {
+			d, err = 0, fmt.Errorf("invalid %s %q: %w", name, v, geneos.ErrInvalidArgs)
+		}
+	}
+	return
+}
+
+func apiRestart(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	all := r.URL.Query().Get("all") == "true"
+	kill := r.URL.Query().Get("kill") == "true"
+	timeout, err := apiDuration(r, "timeout")
+	if err != nil {
+		return nil, err
+	}
+	wait, err := apiDuration(r, "wait")
+	if err != nil {
+		return nil, err
+	}
+	return apiResults(ct, names, func(c geneos.Instance, _ []string) instance.Result {
+		return restart(c, all, kill, timeout, wait)
+	}, nil)
+}
+
+func apiSet(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	var values map[string]string
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&values); err != nil {
+		return nil, fmt.Errorf("request body must be a JSON object of strings: %w", geneos.ErrInvalidArgs)
+	}
+	var params []string
+	for k, v := range values {
+		if k == "" {
+			return nil, fmt.Errorf("empty key: %w", geneos.ErrInvalidArgs)
+		}
+		params = append(params, k+"="+v)
+	}
+	sort.Strings(params)
+	return apiResults(ct, names, func(c geneos.Instance, params []string) instance.Result {
+		return setInstanceValues(c, instance.ExtraConfigValues{}, params)
+	}, params)
+}
+
+func apiUnset(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	var keys []string
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&keys); err != nil || len(keys) == 0 {
+		return nil, fmt.Errorf("request body must be a JSON array of keys: %w", geneos.ErrInvalidArgs)
+	}
+	return apiResults(ct, names, func(c geneos.Instance, _ []string) instance.Result {
+		return unsetInstanceKeys(c, keys, false)
+	}, nil)
+}
+
+func apiRebuild(r *http.Request, ct *geneos.Component, names []string) (interface{}, error) {
+	force := r.URL.Query().Get("force") == "true"
+	reload := r.URL.Query().Get("reload") == "true"
+	// serial, as for the rebuild command
+	return apiResults(ct, names, func(c geneos.Instance, params []string) instance.Result {
+		return rebuild(c, force, reload, params)
+	}, nil, geneos.Parallel(1))
+}
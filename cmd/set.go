@@ -22,11 +22,13 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
 	"wonderland.org/geneos/internal/instance"
 )
 
@@ -46,6 +48,7 @@ Use "rebuild" to do this.`,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "true",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
@@ -57,6 +60,7 @@ func init() {
 	rootCmd.AddCommand(setCmd)
 
 	setCmd.Flags().VarP(&setCmdExtras.Envs, "env", "e", "(all components) Add an environment variable in the format NAME=VALUE")
+	setCmd.Flags().VarP(&setCmdExtras.Labels, "label", "L", "(all components) Add a label in the format NAME=VALUE")
 	setCmd.Flags().VarP(&setCmdExtras.Includes, "include", "i", "(gateways) Add an include file in the format PRIORITY:PATH")
 	setCmd.Flags().VarP(&setCmdExtras.Gateways, "gateway", "g", "(sans) Add a gateway in the format NAME:PORT")
 	setCmd.Flags().VarP(&setCmdExtras.Attributes, "attribute", "a", "(sans) Add an attribute in the format NAME=VALUE")
@@ -72,16 +76,24 @@ var setCmdExtras = instance.ExtraConfigValues{
 	Envs:       instance.StringSliceValues{},
 	Variables:  instance.VarValues{},
 	Types:      instance.StringSliceValues{},
+	Labels:     instance.LabelValues{},
 }
 
 func commandSet(ct *geneos.Component, args, params []string) error {
-	return instance.ForAll(ct, setInstance, args, params)
+	return outputResults(instance.ForAllResults(ct, setInstance, args, params))
 }
 
-func setInstance(c geneos.Instance, params []string) (err error) {
+func setInstance(c geneos.Instance, params []string) (result instance.Result) {
+	return setInstanceValues(c, setCmdExtras, params)
+}
+
+// setInstanceValues applies the extras and the KEY=VALUE params to c and
+// writes the resulting configuration
+func setInstanceValues(c geneos.Instance, extras instance.ExtraConfigValues, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "set")
 	logDebug.Println("c", c, "params", params)
 
-	instance.SetExtendedValues(c, setCmdExtras)
+	instance.SetExtendedValues(c, extras)
 
 	for _, arg := range params {
 		s := strings.SplitN(arg, "=", 2)
@@ -93,12 +105,12 @@ func setInstance(c geneos.Instance, params []string) (err error) {
 	}
 
 	// now loop through the collected results and write out
-	if err = instance.Migrate(c); err != nil {
-		logError.Fatalln("cannot migrate existing .rc config to set values in new .json configration file:", err)
+	if err := instance.Migrate(c); err != nil {
+		return result.Fail(fmt.Errorf("cannot migrate existing .rc config to set values in new .json configration file: %w", err))
 	}
 
-	if err = instance.WriteConfig(c); err != nil {
-		logError.Fatalln(err)
+	if err := instance.WriteConfig(c); err != nil {
+		return result.Fail(err)
 	}
 
 	return
@@ -106,6 +118,9 @@ func setInstance(c geneos.Instance, params []string) (err error) {
 
 // XXX muddled - fix
 func writeConfigParams(filename string, params []string) (err error) {
+	if host.LOCAL.DryRunf("set %s in %s", strings.Join(params, " "), filename) {
+		return
+	}
 	vp := viper.New()
 	vp.SetConfigFile(filename)
 	vp.ReadInConfig()
@@ -26,6 +26,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
 )
 
 // setUserCmd represents the setUser command
@@ -37,6 +38,7 @@ var setUserCmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "false",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
@@ -51,7 +53,7 @@ func init() {
 
 func commandSetUser(ct *geneos.Component, args, params []string) (err error) {
 	userConfDir, _ := os.UserConfigDir()
-	if err = os.MkdirAll(userConfDir, 0775); err != nil {
+	if err = host.LOCAL.MkdirAll(userConfDir, 0775); err != nil {
 		logError.Fatalln(err)
 	}
 	return writeConfigParams(geneos.UserConfigFilePath(), params)
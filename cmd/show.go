@@ -24,6 +24,7 @@ package cmd
 import (
 	"encoding/json"
 	"regexp"
+	"sort"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -48,6 +49,9 @@ configuration for those instances are output as JSON. This is
 regardless of the instance using a legacy .rc file or a native JSON
 configuration.
 
+With -s/--sockets the TCP and UDP sockets that each running instance
+has open are included, with the same details as "ps --sockets".
+
 Passwords and secrets are redacted in a very simplistic manner simply
 to prevent visibility in casual viewing.`,
 	SilenceUsage:          true,
@@ -72,25 +76,47 @@ to prevent visibility in casual viewing.`,
 func init() {
 	rootCmd.AddCommand(showCmd)
 
+	showCmd.Flags().BoolVarP(&showCmdSockets, "sockets", "s", false, "Include the open sockets of running instances")
 	showCmd.Flags().SortFlags = false
 }
 
 // var showCmdYAML bool
 
+var showCmdSockets bool
+
 func commandShow(ct *geneos.Component, args []string, params []string) (err error) {
-	return instance.ForAll(ct, showInstance, args, params)
+	return instance.ForAll(ct, showInstance, args, params, geneos.Parallel(1))
 }
 
 type showCmdConfig struct {
-	Name   string      `json:"name,omitempty"`
-	Host   string      `json:"host,omitempty"`
-	Type   string      `json:"type,omitempty"`
-	Config interface{} `json:"config,omitempty"`
+	Name    string           `json:"name,omitempty"`
+	Host    string           `json:"host,omitempty"`
+	Type    string           `json:"type,omitempty"`
+	Config  interface{}      `json:"config,omitempty"`
+	Sockets []showSocketType `json:"sockets,omitempty"`
+}
+
+type showSocketType struct {
+	Proto  string `json:"proto"`
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+	State  string `json:"state"`
+	Inode  int    `json:"inode"`
 }
 
 func showInstance(c geneos.Instance, params []string) (err error) {
-	var buffer []byte
+	buffer, err := showConfig(c)
+	if err != nil {
+		return
+	}
+	log.Printf("%s\n", string(buffer))
 
+	return
+}
+
+// showConfig returns the configuration of c, without aliases, as
+// indented JSON with secrets redacted
+func showConfig(c geneos.Instance) (buffer []byte, err error) {
 	// remove aliases
 	nv := viper.New()
 	for _, k := range c.V().AllKeys() {
@@ -101,16 +127,41 @@ func showInstance(c geneos.Instance, params []string) (err error) {
 
 	// XXX wrap in location and type
 	cf := &showCmdConfig{Name: c.Name(), Host: c.Host().String(), Type: c.Type().String(), Config: nv.AllSettings()}
+	if showCmdSockets {
+		cf.Sockets = showSockets(c)
+	}
 
 	if buffer, err = json.MarshalIndent(cf, "", "    "); err != nil {
 		return
 	}
 	buffer = opaqueJSONSecrets(buffer)
-	log.Printf("%s\n", string(buffer))
 
 	return
 }
 
+// showSockets returns the open sockets of c, listening sockets first,
+// or nil if it is not running
+func showSockets(c geneos.Instance) (sockets []showSocketType) {
+	if _, err := instance.GetPID(c); err != nil {
+		return
+	}
+	s, err := instance.Sockets(c)
+	if err != nil {
+		logError.Println(c, "cannot read sockets:", err)
+		return
+	}
+	sort.SliceStable(s, func(i, j int) bool {
+		if (s[i].State == "LISTEN") != (s[j].State == "LISTEN") {
+			return s[i].State == "LISTEN"
+		}
+		return s[i].LocalPort < s[j].LocalPort
+	})
+	for _, so := range s {
+		sockets = append(sockets, showSocketType{so.Proto, so.Local(), so.Remote(), so.State, so.Inode})
+	}
+	return
+}
+
 // XXX redact passwords - any field matching some regexp ?
 //
 var red1 = regexp.MustCompile(`"(.*((?i)pass|password|secret))": "(.*)"`)
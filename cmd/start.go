@@ -22,6 +22,8 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 	"wonderland.org/geneos/internal/geneos"
 	"wonderland.org/geneos/internal/instance"
@@ -29,16 +31,40 @@ import (
 
 // startCmd represents the start command
 var startCmd = &cobra.Command{
-	Use:   "start [-l] [TYPE] [NAME...]",
+	Use:   "start [-w TIMEOUT] [-l] [--selector SELECTOR] [TYPE] [NAME...]",
 	Short: "Start instances",
 	Long: `Start one or more matching instances. All instances are run in
 the background and STDOUT and STDERR are redirected to a '.txt' file
 in the instance directory. You can watch the resulting logs files with the
--l flag.`,
+-l/--log flag.
+
+With -w each instance must be ready, which is listening on its
+configured port, within TIMEOUT, for example "-w 30s". Otherwise the
+start fails with the last lines of the instance's '.txt' file.
+
+Instances are started in dependency order. A gateway depends on the
+licd given by its "licdhost" and "licdport", an instance with a
+"gateways" setting, such as a SAN, on those gateways, a webserver on
+all gateways and any instance on the instances listed in its "depends"
+setting, as [TYPE:]NAME[@HOST] separated by commas or spaces. Only the
+matching instances are ordered. Before starting the instances that
+depend on others, start waits for those others to be listening on their
+ports, for up to the "dependencywait" setting (default 60s).
+
+Hooks are shell commands run before and after instances are started,
+stopped and rebuilt. Each is the instance setting "hooks.EVENT" or,
+for all instances of a type, the global setting "hooks.TYPE.EVENT",
+where EVENT is one of prestart, poststart, prestop, poststop,
+prerebuild or postrebuild. They are run with /bin/sh in the instance
+directory on the instance's host and the environment variables
+GENEOS_HOOK, GENEOS_TYPE, GENEOS_NAME, GENEOS_HOST, GENEOS_HOME and
+GENEOS_PORT describe the instance, plus GENEOS_PID for poststart and
+prestop. An instance is not started if its prestart hook fails.`,
 	SilenceUsage:          true,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "true",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
@@ -49,16 +75,16 @@ in the instance directory. You can watch the resulting logs files with the
 func init() {
 	rootCmd.AddCommand(startCmd)
 
+	startCmd.Flags().DurationVarP(&startCmdWait, "wait", "w", 0, "Wait up to `TIMEOUT` for instances to listen on their ports")
 	startCmd.Flags().BoolVarP(&startCmdLogs, "log", "l", false, "Run 'logs -f' after starting instance(s)")
 	startCmd.Flags().SortFlags = false
 }
 
 var startCmdLogs bool
+var startCmdWait time.Duration
 
 func commandStart(ct *geneos.Component, watchlogs bool, args []string, params []string) (err error) {
-	if err = instance.ForAll(ct, func(c geneos.Instance, _ []string) error {
-		return instance.Start(c)
-	}, args, params); err != nil {
+	if err = outputResults(instance.ForAllStages(ct, startInstance, args, params, false)); err != nil {
 		return
 	}
 
@@ -69,3 +95,7 @@ func commandStart(ct *geneos.Component, watchlogs bool, args []string, params []
 
 	return
 }
+
+func startInstance(c geneos.Instance, _ []string) instance.Result {
+	return instance.StartWait(c, startCmdWait)
+}
@@ -22,6 +22,8 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"time"
+
 	"github.com/spf13/cobra"
 	"wonderland.org/geneos/internal/geneos"
 	"wonderland.org/geneos/internal/instance"
@@ -29,21 +31,36 @@ import (
 
 // stopCmd represents the stop command
 var stopCmd = &cobra.Command{
-	Use:   "stop [-K] [TYPE] [NAME...]",
+	Use:   "stop [-K] [-t TIMEOUT] [TYPE] [NAME...]",
 	Short: "Stop instances",
 	Long: `Stop one or more matching instances. Unless the -K
 flag is given, a SIGTERM is sent and if the instance is
-still running after a few seconds then a SIGKILL is sent. If the
+still running after a timeout then a SIGKILL is sent. If the
 -K flag is given the instance(s) are immediately terminated with
-a SIGKILL.`,
+a SIGKILL.
+
+The signal, the timeout and how often to check if the instance has
+exited are the instance settings "stopsignal", "stoptimeout" and
+"stoppoll", which default to the global settings "TYPEStopSignal",
+"TYPEStopTimeout" and "TYPEStopPoll", e.g. "GatewayStopTimeout", and
+then to SIGTERM, 2.5s and 250ms. Gateways default to 60s and webservers
+to 30s. The -t flag overrides the timeout, for example "-t 2m". The
+time each instance took to stop is reported.
+
+Instances are stopped in the reverse of the dependency order used by
+start, so for example SANs are stopped before their gateways.
+
+Unless -K is given the "prestop" and "poststop" hooks are run before
+and after each instance is stopped, see 'geneos help start'.`,
 	SilenceUsage:          true,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "true",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, stopInstance, args, params)
+		return outputResults(instance.ForAllStages(ct, stopInstance, args, params, true))
 	},
 }
 
@@ -51,11 +68,13 @@ func init() {
 	rootCmd.AddCommand(stopCmd)
 
 	stopCmd.Flags().BoolVarP(&stopCmdKill, "kill", "K", false, "Force immediate stop by sending an immediate SIGKILL")
+	stopCmd.Flags().DurationVarP(&stopCmdTimeout, "timeout", "t", 0, "Wait `TIMEOUT` for instances to stop before a SIGKILL, instead of the stop policy")
 	stopCmd.Flags().SortFlags = false
 }
 
 var stopCmdKill bool
+var stopCmdTimeout time.Duration
 
-func stopInstance(c geneos.Instance, params []string) error {
-	return instance.Stop(c, stopCmdKill)
+func stopInstance(c geneos.Instance, params []string) instance.Result {
+	return instance.StopWait(c, stopCmdKill, stopCmdTimeout)
 }
@@ -0,0 +1,199 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+	"wonderland.org/geneos/internal/instance"
+)
+
+var superviseCmd = &cobra.Command{
+	Use:   "supervise [-i INTERVAL] [-b BACKOFF] [-B MAX] [-m COUNT] [-w WINDOW] [-H] [TYPE] [NAME...]",
+	Short: "Restart instances that stop unexpectedly",
+	Long: `Run in the foreground and check the matching instances every
+INTERVAL, restarting any that have stopped unexpectedly.
+
+An instance is only restarted if the supervisor has seen it running
+and it has since stopped without 'geneos stop' (or disable, restart
+etc.) being used. Instances that are disabled or were stopped with
+'geneos stop' are left alone until they are started again.
+
+The first restart is immediate, the delay before each further restart
+starts at BACKOFF and doubles up to MAX. The delay is reset once an
+instance has stayed up for WINDOW. If an instance needs more than
+COUNT restarts in WINDOW then the supervisor gives up on it until it
+is started manually.
+
+Each restart is recorded in the restart history of the instance,
+which is shown with the -H flag.
+
+The supervisor can also be run as part of 'geneos serve' with the
+--supervise flag.`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "true",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ct, args, params := cmdArgsParams(cmd)
+		if superviseCmdHistory {
+			return commandSuperviseHistory(ct, args, params)
+		}
+		host.RetryInterval = superviseCmdInterval
+		supervise(ct, args, params, &sync.Mutex{})
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(superviseCmd)
+
+	superviseFlags(superviseCmd.Flags())
+	superviseCmd.Flags().BoolVarP(&superviseCmdHistory, "history", "H", false, "Show the restart history of instances and exit")
+	superviseCmd.Flags().SortFlags = false
+}
+
+var superviseCmdInterval, superviseCmdBackoff, superviseCmdMaxBackoff, superviseCmdWindow time.Duration
+var superviseCmdMaxRestarts int
+var superviseCmdHistory bool
+
+// superviseFlags adds the supervisor settings to flags, shared by the
+// supervise and serve commands
+func superviseFlags(flags *pflag.FlagSet) {
+	flags.DurationVarP(&superviseCmdInterval, "interval", "i", 10*time.Second, "Check instances at this interval")
+	flags.DurationVarP(&superviseCmdBackoff, "backoff", "b", 5*time.Second, "Initial delay between restarts of an instance")
+	flags.DurationVarP(&superviseCmdMaxBackoff, "maxbackoff", "B", 5*time.Minute, "Maximum delay between restarts of an instance")
+	flags.IntVarP(&superviseCmdMaxRestarts, "maxrestarts", "m", 5, "Give up after this many restarts of an instance in the window")
+	flags.DurationVarP(&superviseCmdWindow, "window", "w", 10*time.Minute, "Window for counting restarts and resetting the delay")
+}
+
+// supervise checks the matching instances every interval and never
+// returns. Each check holds lock, which is shared with any other users
+// of the instances.
+func supervise(ct *geneos.Component, args, params []string, lock sync.Locker) {
+	s := &instance.Supervisor{
+		Backoff:     superviseCmdBackoff,
+		MaxBackoff:  superviseCmdMaxBackoff,
+		MaxRestarts: superviseCmdMaxRestarts,
+		Window:      superviseCmdWindow,
+	}
+	log.Printf("supervising instances every %v", superviseCmdInterval)
+	for {
+		lock.Lock()
+		// reload instances so that changes made by other commands are
+		// seen, e.g. new or deleted instances
+		serveRefresh()
+		results, _ := instance.ForAllResults(ct, func(c geneos.Instance, _ []string) instance.Result {
+			return s.Check(c)
+		}, args, params)
+		lock.Unlock()
+
+		var changed instance.Results
+		for _, r := range results {
+			if r.Outcome == instance.Unchanged {
+				continue
+			}
+			if r.Outcome == instance.Failed && outputFormat == "text" {
+				log.Println(r)
+			}
+			changed = append(changed, r)
+		}
+		outputResults(changed, nil)
+
+		time.Sleep(superviseCmdInterval)
+	}
+}
+
+type superviseHistoryType struct {
+	Type   string
+	Name   string
+	Host   string
+	Time   time.Time
+	Action string
+	PID    int
+	Error  string
+}
+
+func commandSuperviseHistory(ct *geneos.Component, args, params []string) (err error) {
+	rows, err := superviseHistory(ct, args, params)
+
+	switch outputFormat {
+	case "json":
+		jsonEncoder = json.NewEncoder(outputWriter())
+		for _, r := range rows {
+			jsonEncoder.Encode(r)
+		}
+	case "csv":
+		csvWriter = csv.NewWriter(outputWriter())
+		csvWriter.Write([]string{"Type", "Name", "Host", "Time", "Action", "PID", "Error"})
+		for _, r := range rows {
+			csvWriter.Write([]string{r.Type, r.Name, r.Host, r.Time.Format(time.RFC3339), r.Action, fmt.Sprint(r.PID), r.Error})
+		}
+		csvWriter.Flush()
+	default:
+		w := tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "Type\tName\tHost\tTime\tAction\tPID\tError\n")
+		for _, r := range rows {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", r.Type, r.Name, r.Host, r.Time.Local().Format(time.RFC3339), r.Action, r.PID, r.Error)
+		}
+		w.Flush()
+	}
+	if err == os.ErrNotExist {
+		err = nil
+	}
+	return
+}
+
+// superviseHistory returns the restart histories of the matching
+// instances merged in time order
+func superviseHistory(ct *geneos.Component, args, params []string) (rows []superviseHistoryType, err error) {
+	var mutex sync.Mutex
+
+	err = instance.ForAll(ct, func(c geneos.Instance, _ []string) error {
+		history, err := instance.RestartHistory(c)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, h := range history {
+			rows = append(rows, superviseHistoryType{c.Type().String(), c.Name(), c.Host().String(), h.Time, h.Action, h.PID, h.Error})
+		}
+		return nil
+	}, args, params)
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Time.Before(rows[j].Time)
+	})
+	return
+}
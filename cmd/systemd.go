@@ -0,0 +1,225 @@
+/*
+Copyright © 2022 Peter Galbavy <peter@wonderland.org>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+	"wonderland.org/geneos/internal/instance"
+)
+
+var systemdCmd = &cobra.Command{
+	Use:   "systemd [-I [-E] [-M]|-R] [-S SCOPE] [-D DIR] [TYPE] [NAME...]",
+	Short: "Create systemd unit files for instances",
+	Long: `Create a systemd service unit for each matching instance using the
+same command line, environment, working directory and user as the
+start command. The unit is named "geneos-TYPE-NAME.service".
+
+By default the units are written to stdout. With -I the units are
+installed on the host of each instance and systemd is reloaded. -E
+also enables the units so that instances start at boot and -M marks
+the instances as managed by systemd, after which start, stop and
+restart use systemctl and the supervise command leaves the instances
+to systemd. -R reverses an install for stopped instances.
+
+The scope defaults to the system manager, with units in
+/etc/systemd/system, for root and otherwise the user's manager, with
+units in ~/.config/systemd/user. User units on hosts without a login
+session may need 'loginctl enable-linger'.`,
+	SilenceUsage:          true,
+	DisableFlagsInUseLine: true,
+	Annotations: map[string]string{
+		"wildcard": "true",
+	},
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ct, args, params := cmdArgsParams(cmd)
+		return commandSystemd(ct, args, params)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(systemdCmd)
+
+	systemdCmd.Flags().BoolVarP(&systemdCmdInstall, "install", "I", false, "Install units and reload systemd")
+	systemdCmd.Flags().BoolVarP(&systemdCmdEnable, "enable", "E", false, "Enable installed units")
+	systemdCmd.Flags().BoolVarP(&systemdCmdManage, "manage", "M", false, "Mark instances as managed by systemd")
+	systemdCmd.Flags().BoolVarP(&systemdCmdRemove, "remove", "R", false, "Disable and remove units and unmark instances")
+	systemdCmd.Flags().StringVarP(&systemdCmdScope, "scope", "S", "", "systemd scope, \"system\" or \"user\" (default depends on user)")
+	systemdCmd.Flags().StringVarP(&systemdCmdDir, "dir", "D", "", "Install units in this directory (default depends on scope)")
+	systemdCmd.Flags().SortFlags = false
+}
+
+var systemdCmdInstall, systemdCmdEnable, systemdCmdManage, systemdCmdRemove bool
+var systemdCmdScope, systemdCmdDir string
+
+// systemdReload records the hosts and scopes where units have changed
+type systemdReload struct {
+	h     *host.Host
+	scope string
+}
+
+func commandSystemd(ct *geneos.Component, args, params []string) (err error) {
+	if systemdCmdScope != "" && systemdCmdScope != instance.SystemdSystem && systemdCmdScope != instance.SystemdUser {
+		return fmt.Errorf("scope must be %q or %q: %w", instance.SystemdSystem, instance.SystemdUser, ErrInvalidArgs)
+	}
+	if systemdCmdInstall && systemdCmdRemove {
+		return fmt.Errorf("only one of --install and --remove: %w", ErrInvalidArgs)
+	}
+	if !systemdCmdInstall && !systemdCmdRemove {
+		// units are printed in order
+		return instance.ForAll(ct, systemdPrintInstance, args, params, geneos.Parallel(1))
+	}
+
+	reloads := make(map[systemdReload]bool)
+	var mutex sync.Mutex
+	fn := systemdInstallInstance
+	if systemdCmdRemove {
+		fn = systemdRemoveInstance
+	}
+	results, err := instance.ForAllResults(ct, func(c geneos.Instance, params []string) instance.Result {
+		result := fn(c, params)
+		if result.Outcome == instance.OK {
+			mutex.Lock()
+			reloads[systemdReload{c.Host(), systemdInstanceScope(c)}] = true
+			mutex.Unlock()
+		}
+		return result
+	}, args, params)
+
+	for r := range reloads {
+		if _, err := instance.Systemctl(r.h, r.scope, "daemon-reload"); err != nil {
+			logError.Printf("%s: %s", r.h, err)
+		}
+	}
+	return outputResults(results, err)
+}
+
+// systemdInstanceScope returns the scope for c, from the command line,
+// the instance settings or the default for the host in that order
+func systemdInstanceScope(c geneos.Instance) string {
+	if systemdCmdScope != "" {
+		return systemdCmdScope
+	}
+	if instance.IsSystemd(c) {
+		return c.V().GetString("systemd")
+	}
+	return instance.SystemdScope(c.Host())
+}
+
+func systemdUnitPath(c geneos.Instance) (string, error) {
+	dir := systemdCmdDir
+	if dir == "" {
+		var err error
+		if dir, err = instance.SystemdUnitDir(c.Host(), systemdInstanceScope(c)); err != nil {
+			return "", err
+		}
+	}
+	return path.Join(dir, instance.SystemdUnitName(c)), nil
+}
+
+func systemdPrintInstance(c geneos.Instance, params []string) (err error) {
+	unit, err := instance.SystemdUnit(c, systemdInstanceScope(c))
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(outputWriter(), "# %s on %s\n%s\n", instance.SystemdUnitName(c), c.Host(), unit)
+	return
+}
+
+func systemdInstallInstance(c geneos.Instance, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "systemd")
+	scope := systemdInstanceScope(c)
+	unit, err := instance.SystemdUnit(c, scope)
+	if err != nil {
+		return result.Fail(err)
+	}
+	p, err := systemdUnitPath(c)
+	if err != nil {
+		return result.Fail(err)
+	}
+	if err = c.Host().MkdirAll(path.Dir(p), 0755); err != nil {
+		return result.Fail(err)
+	}
+	if err = c.Host().WriteFile(p, unit, 0644); err != nil {
+		return result.Fail(err)
+	}
+	result = result.Set(instance.OK, "installed %s", p)
+
+	if systemdCmdEnable {
+		if _, err = instance.Systemctl(c.Host(), scope, "enable", instance.SystemdUnitName(c)); err != nil {
+			return result.Fail(err)
+		}
+		result.Message += ", enabled"
+	}
+
+	if systemdCmdManage && c.V().GetString("systemd") != scope {
+		c.V().Set("systemd", scope)
+		if err = instance.Migrate(c); err != nil {
+			return result.Fail(err)
+		}
+		if err = instance.WriteConfig(c); err != nil {
+			return result.Fail(err)
+		}
+		result.Message += ", managed by systemd"
+	}
+	return
+}
+
+func systemdRemoveInstance(c geneos.Instance, params []string) (result instance.Result) {
+	result = instance.NewResult(c, "systemd")
+	if _, err := instance.GetPID(c); err == nil {
+		return result.Fail(fmt.Errorf("instance is running, stop it first"))
+	}
+	p, err := systemdUnitPath(c)
+	if err != nil {
+		return result.Fail(err)
+	}
+	if _, err = c.Host().Stat(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return result.Set(instance.Unchanged, "")
+		}
+		return result.Fail(err)
+	}
+
+	scope := systemdInstanceScope(c)
+	if _, err = instance.Systemctl(c.Host(), scope, "disable", instance.SystemdUnitName(c)); err != nil {
+		logDebug.Println(c, err)
+	}
+	if err = c.Host().Remove(p); err != nil {
+		return result.Fail(err)
+	}
+	result = result.Set(instance.OK, "removed %s", p)
+
+	if instance.IsSystemd(c) {
+		if r := unsetInstanceKeys(c, []string{"systemd"}, false); r.Outcome == instance.Failed {
+			return result.Fail(r.Err)
+		}
+	}
+	return
+}
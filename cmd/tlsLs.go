@@ -23,11 +23,14 @@ package cmd
 
 import (
 	"crypto/sha1"
+	"crypto/x509"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"net"
 	"os"
+	"sort"
+	"sync"
 	"text/tabwriter"
 	"time"
 
@@ -70,6 +73,8 @@ func init() {
 var tlsCmdAll, tlsCmdCSV, tlsCmdJSON, tlsCmdIndent, tlsCmdLong bool
 
 func commandTLSLs(ct *geneos.Component, args []string, params []string) (err error) {
+	tlsCmdJSON = tlsCmdJSON || outputFormat == "json"
+	tlsCmdCSV = tlsCmdCSV || outputFormat == "csv"
 	if tlsCmdLong {
 		return listCertsLongCommand(ct, args, params)
 	}
@@ -104,7 +109,7 @@ func listCertsCommand(ct *geneos.Component, args []string, params []string) (err
 
 	switch {
 	case tlsCmdJSON:
-		jsonEncoder = json.NewEncoder(log.Writer())
+		jsonEncoder = json.NewEncoder(outputWriter())
 		if tlsCmdIndent {
 			jsonEncoder.SetIndent("", "    ")
 		}
@@ -130,9 +135,12 @@ func listCertsCommand(ct *geneos.Component, args []string, params []string) (err
 				})
 			}
 		}
-		err = instance.ForAll(ct, lsInstanceCertJSON, args, params)
+		err = readInstanceCerts(ct, args, params)
+		for _, r := range certRows {
+			lsInstanceCertJSON(r)
+		}
 	case tlsCmdCSV:
-		csvWriter = csv.NewWriter(log.Writer())
+		csvWriter = csv.NewWriter(outputWriter())
 		csvWriter.Write([]string{
 			"Type",
 			"Name",
@@ -163,10 +171,13 @@ func listCertsCommand(ct *geneos.Component, args []string, params []string) (err
 				})
 			}
 		}
-		err = instance.ForAll(ct, lsInstanceCertCSV, args, params)
+		err = readInstanceCerts(ct, args, params)
+		for _, r := range certRows {
+			lsInstanceCertCSV(r)
+		}
 		csvWriter.Flush()
 	default:
-		lsTabWriter = tabwriter.NewWriter(log.Writer(), 3, 8, 2, ' ', 0)
+		lsTabWriter = tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
 		fmt.Fprintf(lsTabWriter, "Type\tName\tHost\tRemaining\tExpires\tCommonName\n")
 		if tlsCmdAll {
 			if rootCert != nil {
@@ -180,7 +191,10 @@ func listCertsCommand(ct *geneos.Component, args []string, params []string) (err
 					geneosCert.Subject.CommonName)
 			}
 		}
-		err = instance.ForAll(ct, lsInstanceCert, args, params)
+		err = readInstanceCerts(ct, args, params)
+		for _, r := range certRows {
+			lsInstanceCert(r)
+		}
 		lsTabWriter.Flush()
 	}
 	return
@@ -192,7 +206,7 @@ func listCertsLongCommand(ct *geneos.Component, args []string, params []string)
 
 	switch {
 	case tlsCmdJSON:
-		jsonEncoder = json.NewEncoder(log.Writer())
+		jsonEncoder = json.NewEncoder(outputWriter())
 		if tlsCmdIndent {
 			jsonEncoder.SetIndent("", "    ")
 		}
@@ -226,9 +240,12 @@ func listCertsLongCommand(ct *geneos.Component, args []string, params []string)
 				})
 			}
 		}
-		err = instance.ForAll(ct, lsInstanceCertJSON, args, params)
+		err = readInstanceCerts(ct, args, params)
+		for _, r := range certRows {
+			lsInstanceCertJSON(r)
+		}
 	case tlsCmdCSV:
-		csvWriter = csv.NewWriter(log.Writer())
+		csvWriter = csv.NewWriter(outputWriter())
 		csvWriter.Write([]string{
 			"Type",
 			"Name",
@@ -271,10 +288,13 @@ func listCertsLongCommand(ct *geneos.Component, args []string, params []string)
 				})
 			}
 		}
-		err = instance.ForAll(ct, lsInstanceCertCSV, args, params)
+		err = readInstanceCerts(ct, args, params)
+		for _, r := range certRows {
+			lsInstanceCertCSV(r)
+		}
 		csvWriter.Flush()
 	default:
-		lsTabWriter = tabwriter.NewWriter(log.Writer(), 3, 8, 2, ' ', 0)
+		lsTabWriter = tabwriter.NewWriter(outputWriter(), 3, 8, 2, ' ', 0)
 		fmt.Fprintf(lsTabWriter, "Type\tName\tHost\tRemaining\tExpires\tCommonName\tIssuer\tSubjAltNames\tIPs\tFingerprint\n")
 		if tlsCmdAll {
 			if rootCert != nil {
@@ -288,14 +308,37 @@ func listCertsLongCommand(ct *geneos.Component, args []string, params []string)
 					geneosCert.Subject.CommonName, geneosCert.Issuer.CommonName, sha1.Sum(geneosCert.Raw))
 			}
 		}
-		err = instance.ForAll(ct, lsInstanceCert, args, params)
+		err = readInstanceCerts(ct, args, params)
+		for _, r := range certRows {
+			lsInstanceCert(r)
+		}
 		lsTabWriter.Flush()
 	}
 	return
 }
 
-func lsInstanceCert(c geneos.Instance, params []string) (err error) {
-	cert, err := instance.ReadCert(c)
+type certRow struct {
+	Type string
+	Name string
+	Host string
+	cert *x509.Certificate
+}
+
+// rows are collected by concurrent workers and output once sorted
+var certRows []certRow
+var certRowsMutex sync.Mutex
+
+func readInstanceCerts(ct *geneos.Component, args []string, params []string) (err error) {
+	certRows = nil
+	err = instance.ForAll(ct, readInstanceCert, args, params)
+	sort.Slice(certRows, func(i, j int) bool {
+		return lessInstance(certRows[i].Type, certRows[i].Name, certRows[i].Host, certRows[j].Type, certRows[j].Name, certRows[j].Host)
+	})
+	return
+}
+
+func readInstanceCert(c geneos.Instance, params []string) (err error) {
+	row, err := newCertRow(c)
 	if err == os.ErrNotExist {
 		// this is OK - instance.ReadCert() reports no configured cert this way
 		return nil
@@ -303,8 +346,24 @@ func lsInstanceCert(c geneos.Instance, params []string) (err error) {
 	if err != nil {
 		return
 	}
+	certRowsMutex.Lock()
+	certRows = append(certRows, row)
+	certRowsMutex.Unlock()
+	return
+}
+
+func newCertRow(c geneos.Instance) (row certRow, err error) {
+	cert, err := instance.ReadCert(c)
+	if err != nil {
+		return
+	}
+	return certRow{c.Type().String(), c.Name(), c.Host().String(), cert}, nil
+}
+
+func lsInstanceCert(r certRow) {
+	cert := r.cert
 	expires := cert.NotAfter
-	fmt.Fprintf(lsTabWriter, "%s\t%s\t%s\t%.f\t%q\t%q\t", c.Type(), c.Name(), c.Host(), time.Until(expires).Seconds(), expires, cert.Subject.CommonName)
+	fmt.Fprintf(lsTabWriter, "%s\t%s\t%s\t%.f\t%q\t%q\t", r.Type, r.Name, r.Host, time.Until(expires).Seconds(), expires, cert.Subject.CommonName)
 
 	if tlsCmdLong {
 		fmt.Fprintf(lsTabWriter, "%q\t", cert.Issuer.CommonName)
@@ -318,21 +377,13 @@ func lsInstanceCert(c geneos.Instance, params []string) (err error) {
 		fmt.Fprintf(lsTabWriter, "\t%X", sha1.Sum(cert.Raw))
 	}
 	fmt.Fprint(lsTabWriter, "\n")
-	return
 }
 
-func lsInstanceCertCSV(c geneos.Instance, params []string) (err error) {
-	cert, err := instance.ReadCert(c)
-	if err == os.ErrNotExist {
-		// this is OK
-		return nil
-	}
-	if err != nil {
-		return
-	}
+func lsInstanceCertCSV(r certRow) {
+	cert := r.cert
 	expires := cert.NotAfter
 	until := fmt.Sprintf("%0f", time.Until(expires).Seconds())
-	cols := []string{c.Type().String(), c.Name(), c.Host().String(), until, expires.String(), cert.Subject.CommonName}
+	cols := []string{r.Type, r.Name, r.Host, until, expires.String(), cert.Subject.CommonName}
 	if tlsCmdLong {
 		cols = append(cols, cert.Issuer.CommonName)
 		cols = append(cols, fmt.Sprintf("%v", cert.DNSNames))
@@ -341,24 +392,19 @@ func lsInstanceCertCSV(c geneos.Instance, params []string) (err error) {
 	}
 
 	csvWriter.Write(cols)
-	return
 }
 
-func lsInstanceCertJSON(c geneos.Instance, params []string) (err error) {
-	cert, err := instance.ReadCert(c)
-	if err == os.ErrNotExist {
-		// this is OK
-		return nil
-	}
-	if err != nil {
-		return
-	}
+func lsInstanceCertJSON(r certRow) {
 	if tlsCmdLong {
-		jsonEncoder.Encode(lsCertLongType{c.Type().String(), c.Name(), c.Host().String(), time.Duration(time.Until(cert.NotAfter).Seconds()),
-			cert.NotAfter, cert.Subject.CommonName, cert.Issuer.CommonName, cert.DNSNames, cert.IPAddresses, fmt.Sprintf("%X", sha1.Sum(cert.Raw))})
+		jsonEncoder.Encode(newLsCertLongType(r))
 	} else {
-		jsonEncoder.Encode(lsCertType{c.Type().String(), c.Name(), c.Host().String(), time.Duration(time.Until(cert.NotAfter).Seconds()),
-			cert.NotAfter, cert.Subject.CommonName})
+		jsonEncoder.Encode(lsCertType{r.Type, r.Name, r.Host, time.Duration(time.Until(r.cert.NotAfter).Seconds()),
+			r.cert.NotAfter, r.cert.Subject.CommonName})
 	}
-	return
+}
+
+func newLsCertLongType(r certRow) lsCertLongType {
+	cert := r.cert
+	return lsCertLongType{r.Type, r.Name, r.Host, time.Duration(time.Until(cert.NotAfter).Seconds()),
+		cert.NotAfter, cert.Subject.CommonName, cert.Issuer.CommonName, cert.DNSNames, cert.IPAddresses, fmt.Sprintf("%X", sha1.Sum(cert.Raw))}
 }
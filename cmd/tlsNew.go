@@ -39,7 +39,7 @@ var tlsNewCmd = &cobra.Command{
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, newInstanceCert, args, params)
+		return outputResults(instance.ForAllResults(ct, newInstanceCert, args, params))
 	},
 }
 
@@ -48,6 +48,16 @@ func init() {
 	tlsNewCmd.Flags().SortFlags = false
 }
 
-func newInstanceCert(c geneos.Instance, _ []string) (err error) {
-	return instance.CreateCert(c)
+// create a certificate and key for an instance unless one exists
+func newInstanceCert(c geneos.Instance, _ []string) (result instance.Result) {
+	result = instance.NewResult(c, "tls new")
+	if _, err := instance.ReadCert(c); err == nil {
+		return result.Set(instance.Unchanged, "")
+	}
+
+	expires, err := instance.NewCert(c)
+	if err != nil {
+		return result.Fail(err)
+	}
+	return result.Set(instance.OK, "certificate created (expires %s)", expires)
 }
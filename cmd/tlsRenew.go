@@ -47,10 +47,11 @@ private key file is left unchanged if it exists.`,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "true",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
-		return instance.ForAll(ct, renewInstanceCert, args, params)
+		return outputResults(instance.ForAllResults(ct, renewInstanceCert, args, params))
 	},
 }
 
@@ -60,7 +61,8 @@ func init() {
 }
 
 // renew an instance certificate, use private key if it exists
-func renewInstanceCert(c geneos.Instance, _ []string) (err error) {
+func renewInstanceCert(c geneos.Instance, _ []string) (result instance.Result) {
+	result = instance.NewResult(c, "tls renew")
 	tlsDir := filepath.Join(host.Geneos(), "tls")
 
 	hostname, _ := os.Hostname()
@@ -70,7 +72,7 @@ func renewInstanceCert(c geneos.Instance, _ []string) (err error) {
 
 	serial, err := rand.Prime(rand.Reader, 64)
 	if err != nil {
-		return
+		return result.Fail(err)
 	}
 	expires := time.Now().AddDate(1, 0, 0)
 	template := x509.Certificate{
@@ -89,31 +91,32 @@ func renewInstanceCert(c geneos.Instance, _ []string) (err error) {
 
 	intrCert, err := host.LOCAL.ReadCert(filepath.Join(tlsDir, geneos.SigningCertFile+".pem"))
 	if err != nil {
-		return
+		return result.Fail(err)
 	}
 	intrKey, err := host.LOCAL.ReadKey(filepath.Join(tlsDir, geneos.SigningCertFile+".key"))
 	if err != nil {
-		return
+		return result.Fail(err)
 	}
 
 	// read existing key or create a new one
 	existingKey, _ := instance.ReadKey(c)
 	cert, key, err := instance.CreateCertKey(&template, intrCert, intrKey, existingKey)
 	if err != nil {
-		return
+		return result.Fail(err)
 	}
 
 	if err = instance.WriteCert(c, cert); err != nil {
-		return
+		return result.Fail(err)
 	}
 
 	if existingKey == nil {
 		if err = instance.WriteKey(c, key); err != nil {
-			return
+			return result.Fail(err)
 		}
 	}
 
-	log.Printf("certificate renewed for %s (expires %s)", c, expires)
-
-	return
+	if host.DryRun {
+		return result.Set(instance.OK, "would renew certificate (expires %s)", expires)
+	}
+	return result.Set(instance.OK, "certificate renewed (expires %s)", expires)
 }
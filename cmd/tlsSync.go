@@ -44,6 +44,7 @@ exist.`,
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "false",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		return TLSSync()
@@ -82,7 +83,9 @@ func TLSSync() (err error) {
 			return
 		}
 
-		log.Println("Updated chain.pem on", r.String())
+		if !host.DryRun {
+			log.Println("Updated chain.pem on", r.String())
+		}
 	}
 	return
 }
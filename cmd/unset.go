@@ -22,6 +22,7 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -44,6 +45,7 @@ geneos unset san -g Gateway1
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "true",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args := cmdArgs(cmd)
@@ -55,6 +57,7 @@ func init() {
 	rootCmd.AddCommand(unsetCmd)
 	unsetCmd.Flags().VarP(&unsetCmdKeys, "key", "k", "Unset a configuration key item")
 	unsetCmd.Flags().VarP(&unsetCmdEnvs, "env", "e", "Remove an environment variable of NAME")
+	unsetCmd.Flags().VarP(&unsetCmdLabels, "label", "L", "Remove a label of NAME")
 	unsetCmd.Flags().VarP(&unsetCmdIncludes, "include", "i", "Remove an include file in the format PRIORITY")
 	unsetCmd.Flags().VarP(&unsetCmdGateways, "gateway", "g", "Remove gateway NAME")
 	unsetCmd.Flags().VarP(&unsetCmdAttributes, "attribute", "a", "Remove an attribute of NAME")
@@ -70,33 +73,46 @@ var unsetCmdAttributes = unsetCmdValues{}
 var unsetCmdEnvs = unsetCmdValues{}
 var unsetCmdVariables = unsetCmdValues{}
 var unsetCmdTypes = unsetCmdValues{}
+var unsetCmdLabels = unsetCmdValues{}
 
 func commandUnset(ct *geneos.Component, args []string) error {
-	return instance.ForAll(ct, unsetInstance, args, []string{})
+	return outputResults(instance.ForAllResults(ct, unsetInstance, args, []string{}))
 }
 
-func unsetInstance(c geneos.Instance, params []string) (err error) {
-	var changed bool
+func unsetInstance(c geneos.Instance, params []string) (result instance.Result) {
 	logDebug.Println("c", c, "params", params)
 
-	changed, err = unsetMaps(c)
+	changed, err := unsetMaps(c)
+	if err != nil {
+		return instance.NewResult(c, "unset").Fail(err)
+	}
+
+	return unsetInstanceKeys(c, unsetCmdKeys, changed)
+}
 
+// unsetInstanceKeys removes keys from the configuration of c and writes
+// it out if anything has changed, including any earlier changes to the
+// loaded configuration indicated by changed
+func unsetInstanceKeys(c geneos.Instance, keys []string, changed bool) (result instance.Result) {
+	result = instance.NewResult(c, "unset")
 	s := c.V().AllSettings()
 
-	if len(unsetCmdKeys) > 0 {
-		for _, k := range unsetCmdKeys {
+	if len(keys) > 0 {
+		for _, k := range keys {
 			delete(s, k)
 			changed = true
 		}
 	}
-	if changed {
-		if err = instance.Migrate(c); err != nil {
-			logError.Fatalln("cannot migrate existing .rc config to set values in new .json configration file:", err)
-		}
+	if !changed {
+		return result.Set(instance.Unchanged, "")
+	}
 
-		if err = instance.WriteConfigValues(c, s); err != nil {
-			logError.Fatalln(err)
-		}
+	if err := instance.Migrate(c); err != nil {
+		return result.Fail(fmt.Errorf("cannot migrate existing .rc config to set values in new .json configration file: %w", err))
+	}
+
+	if err := instance.WriteConfigValues(c, s); err != nil {
+		return result.Fail(err)
 	}
 
 	return
@@ -116,6 +132,10 @@ func unsetMaps(c geneos.Instance) (changed bool, err error) {
 		changed = true
 	}
 
+	if unsetMap(c, unsetCmdLabels.lower(), "labels") {
+		changed = true
+	}
+
 	if unsetSlice(c, unsetCmdAttributes, "attributes", func(a, b string) bool {
 		return strings.HasPrefix(a, b+"=")
 	}) {
@@ -176,6 +196,14 @@ func (i *unsetCmdValues) Set(value string) error {
 	return nil
 }
 
+// lower returns the values in lower case, for case insensitive keys
+func (i unsetCmdValues) lower() (l unsetCmdValues) {
+	for _, v := range i {
+		l = append(l, strings.ToLower(v))
+	}
+	return
+}
+
 func (i *unsetCmdValues) Type() string {
 	return "SETTING"
 }
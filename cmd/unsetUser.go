@@ -22,9 +22,12 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
 )
 
 // unsetUserCmd represents the unsetUser command
@@ -36,6 +39,7 @@ var unsetUserCmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "false",
+		"dryrun":   "true",
 	},
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		ct, args, params := cmdArgsParams(cmd)
@@ -66,6 +70,9 @@ OUTER:
 
 	if changed {
 		logDebug.Println(orig.AllSettings())
+		if host.LOCAL.DryRunf("remove %s from %s", strings.Join(args, " "), geneos.UserConfigFilePath()) {
+			return nil
+		}
 		new.SetConfigFile(geneos.UserConfigFilePath())
 		return new.WriteConfig()
 	}
@@ -71,6 +71,7 @@ geneos update netprobe 5.13.2
 	DisableFlagsInUseLine: true,
 	Annotations: map[string]string{
 		"wildcard": "false",
+		"dryrun":   "true",
 	},
 	Args: cobra.RangeArgs(0, 2),
 	RunE: func(cmd *cobra.Command, _ []string) error {
@@ -101,8 +102,12 @@ func commandUpdate(ct *geneos.Component, args []string, params []string) (err er
 	if cmdUpdateRestart {
 		cs := instance.MatchKeyValue(host.ALL, ct, "version", cmdUpdateBase)
 		for _, c := range cs {
-			instance.Stop(c, false)
-			defer instance.Start(c)
+			if r := instance.Stop(c, false); r.Message != "" {
+				log.Println(r)
+			}
+			defer func(c geneos.Instance) {
+				log.Println(instance.Start(c))
+			}(c)
 		}
 	}
 	if err = geneos.Update(r, ct, options...); err != nil && errors.Is(err, os.ErrNotExist) {
@@ -0,0 +1,196 @@
+// Geneos dashboard - a thin client of the /api/v1/ REST API
+
+"use strict";
+
+const api = "/api/v1/";
+let token = sessionStorage.getItem("geneos-token") || "";
+let selected = null;
+
+function $(id) {
+    return document.getElementById(id);
+}
+
+function status(text, error) {
+    $("status").textContent = text;
+    $("status").className = error ? "error" : "";
+}
+
+async function call(method, path, params, body) {
+    const url = new URL(api + path, window.location);
+    for (const [k, v] of params || []) {
+        url.searchParams.append(k, v);
+    }
+    const opts = { method: method, headers: { "Authorization": "Bearer " + token } };
+    if (body !== undefined) {
+        opts.body = JSON.stringify(body);
+        opts.headers["Content-Type"] = "application/json";
+    }
+    const resp = await fetch(url, opts);
+    const data = await resp.json();
+    if (!resp.ok) {
+        if (resp.status == 401) {
+            logout();
+        }
+        throw new Error(data.error || resp.statusText);
+    }
+    return data;
+}
+
+function instanceParams(i) {
+    return [["type", i.Type], ["name", i.Name + "@" + i.Host]];
+}
+
+function cell(row, text, className) {
+    const td = row.insertCell();
+    td.textContent = text;
+    if (className) {
+        td.className = className;
+    }
+    return td;
+}
+
+function button(td, label, fn) {
+    const b = document.createElement("button");
+    b.textContent = label;
+    b.addEventListener("click", fn);
+    td.appendChild(b);
+}
+
+// report the results of an action, listing only failures in detail
+function report(action, results) {
+    const failed = results.filter(r => r.Outcome == "failed");
+    if (failed.length > 0) {
+        status(action + " failed: " + failed.map(r => r.Type + ":" + r.Name + "@" + r.Host + " " + r.Error).join(", "), true);
+    } else {
+        status(action + ": " + results.map(r => r.Type + ":" + r.Name + "@" + r.Host + " " + (r.Message || r.Outcome)).join(", "));
+    }
+}
+
+async function action(path, i, params, body) {
+    try {
+        status(path + " " + i.Type + ":" + i.Name + "@" + i.Host + " ...");
+        report(path, await call("POST", path, instanceParams(i).concat(params || []), body));
+    } catch (e) {
+        status(path + ": " + e.message, true);
+    }
+    await refresh();
+}
+
+async function refresh() {
+    try {
+        const [hosts, instances, ps] = await Promise.all([
+            call("GET", "hosts"),
+            call("GET", "instances"),
+            call("GET", "ps"),
+        ]);
+        showHosts(hosts);
+        showInstances(instances, ps);
+        if (selected) {
+            await showConfig(selected);
+        }
+        $("main").hidden = false;
+    } catch (e) {
+        status(e.message, true);
+    }
+}
+
+function showHosts(hosts) {
+    const tbody = $("hosts").tBodies[0];
+    tbody.replaceChildren();
+    for (const h of hosts) {
+        const row = tbody.insertRow();
+        cell(row, h.Name);
+        cell(row, h.Username);
+        cell(row, h.Hostname);
+        cell(row, h.Port);
+        cell(row, h.Directory);
+    }
+}
+
+function showInstances(instances, ps) {
+    const pids = new Map(ps.map(p => [p.Type + ":" + p.Name + "@" + p.Host, p]));
+    const tbody = $("instances").tBodies[0];
+    tbody.replaceChildren();
+    for (const i of instances) {
+        const row = tbody.insertRow();
+        const p = pids.get(i.Type + ":" + i.Name + "@" + i.Host);
+        cell(row, i.Type);
+        cell(row, i.Name);
+        cell(row, i.Host);
+        cell(row, i.Port);
+        cell(row, i.Version);
+        if (i.Disabled == "Y") {
+            row.className = "disabled";
+            cell(row, "disabled");
+        } else if (p) {
+            cell(row, "running, PID " + p.PID + " since " + p.Starttime, "running");
+        } else {
+            cell(row, "stopped", "stopped");
+        }
+        const td = row.insertCell();
+        button(td, "Start", () => action("start", i));
+        button(td, "Stop", () => action("stop", i));
+        button(td, "Restart", () => action("restart", i, [["all", "true"]]));
+        button(td, "Rebuild", () => action("rebuild", i));
+        button(td, "Config", () => showConfig(i));
+    }
+}
+
+async function showConfig(i) {
+    try {
+        const configs = await call("GET", "show", instanceParams(i));
+        selected = i;
+        $("config-instance").textContent = i.Type + ":" + i.Name + "@" + i.Host;
+        $("config-json").textContent = JSON.stringify(configs[0].config, null, 4);
+        $("config").hidden = false;
+    } catch (e) {
+        selected = null;
+        $("config").hidden = true;
+        status(e.message, true);
+    }
+}
+
+function logout() {
+    token = "";
+    sessionStorage.removeItem("geneos-token");
+    selected = null;
+    $("main").hidden = true;
+    $("login").hidden = false;
+    $("refresh").hidden = true;
+    $("logout").hidden = true;
+}
+
+function login() {
+    $("login").hidden = true;
+    $("refresh").hidden = false;
+    $("logout").hidden = false;
+    status("");
+    refresh();
+}
+
+$("login").addEventListener("submit", e => {
+    e.preventDefault();
+    token = $("token").value.trim();
+    $("token").value = "";
+    sessionStorage.setItem("geneos-token", token);
+    login();
+});
+
+$("refresh").addEventListener("click", refresh);
+$("logout").addEventListener("click", logout);
+
+$("set").addEventListener("submit", e => {
+    e.preventDefault();
+    const values = {};
+    values[$("set-key").value] = $("set-value").value;
+    action("set", selected, [], values);
+});
+
+$("unset").addEventListener("submit", e => {
+    e.preventDefault();
+    action("unset", selected, [], [$("unset-key").value]);
+});
+
+if (token) {
+    login();
+}
@@ -82,8 +82,8 @@ func OpenComponentArchive(ct *Component, options ...GeneosOptions) (body io.Read
 		return
 	}
 
-	// transient download
-	if opts.nosave {
+	// transient download, and nothing is saved in dry run mode
+	if opts.nosave || host.LOCAL.DryRunf("download %s package version %q to %s", ct, opts.version, archivePath) {
 		body = resp.Body
 		return
 	}
@@ -161,6 +161,10 @@ func Unarchive(r *host.Host, ct *Component, filename string, gz io.Reader, optio
 		// XXX - option to delete and overwrite?
 		return
 	}
+	// without unpacking there is no new version for Update to find
+	if r.DryRunf("unpack %s to %s and update the base links for %s", filename, basedir, ct) {
+		return
+	}
 	if err = r.MkdirAll(basedir, 0775); err != nil {
 		return
 	}
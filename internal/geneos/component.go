@@ -2,6 +2,7 @@ package geneos
 
 import (
 	"path/filepath"
+	"syscall"
 
 	"github.com/spf13/viper"
 	"wonderland.org/geneos/internal/host"
@@ -32,6 +33,7 @@ type Component struct {
 	Defaults         []string // ordered list of key=value pairs
 	GlobalSettings   map[string]string
 	Directories      []string
+	ReloadSignal     syscall.Signal // signal to reload configuration, zero if not supported
 }
 
 type Instance interface {
@@ -90,6 +92,18 @@ var Root Component = Component{
 		"reservednames": "",
 
 		"privatekeys": "id_rsa,id_ecdsa,id_ecdsa_sk,id_ed25519,id_ed25519_sk,id_dsa",
+
+		// Maximum number of instances to act on concurrently
+		"parallel": "8",
+
+		// Default maximum number of concurrent actions against any one
+		// host, overridden by a "parallel" setting in the host config.
+		// Keep this below the sshd MaxSessions setting on remotes.
+		"hostparallel": "4",
+
+		// How long start waits for an instance that others depend on
+		// to be listening on its port before starting the next stage
+		"dependencywait": "60s",
 	},
 	Directories: []string{
 		"packages/downloads",
@@ -22,6 +22,9 @@ var (
 const RootCAFile = "rootCA"
 const SigningCertFile = "geneos"
 const DisableExtension = "disabled"
+const StoppedExtension = "stopped"
+const RestartsExtension = "restarts"
+const PIDExtension = "pid"
 const GlobalConfigPath = "/etc/geneos/geneos.json"
 const UserConfigFile = "geneos.json"
 
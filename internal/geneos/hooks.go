@@ -0,0 +1,64 @@
+package geneos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"wonderland.org/geneos/internal/host"
+)
+
+// lifecycle hook events. Each is a shell command in an instance setting
+// "hooks.EVENT" or, for all instances of a component type, the global
+// setting "hooks.TYPE.EVENT". Update hooks are only global settings as
+// they apply to a component type on a host and not to an instance.
+const (
+	HookPreStart    = "prestart"
+	HookPostStart   = "poststart"
+	HookPreStop     = "prestop"
+	HookPostStop    = "poststop"
+	HookPreRebuild  = "prerebuild"
+	HookPostRebuild = "postrebuild"
+	HookPreUpdate   = "preupdate"
+	HookPostUpdate  = "postupdate"
+)
+
+// ComponentHook returns the global hook command for event for
+// component type ct, or an empty string if there is none
+func ComponentHook(ct *Component, event string) string {
+	return viper.GetString("hooks." + ct.String() + "." + event)
+}
+
+// RunHook runs the shell command on host h in directory dir with the
+// environment variables in env, in the form NAME=VALUE, added. The
+// command is run with /bin/sh, through an ssh session for remote hosts
+// and, if sudouser is not empty, as that user through sudo. An error
+// includes any output from the command.
+func RunHook(h *host.Host, sudouser, event, command, dir string, env []string) (err error) {
+	var script strings.Builder
+	script.WriteString("GENEOS_HOOK=" + host.ShellQuote(event))
+	for _, e := range env {
+		if kv := strings.SplitN(e, "=", 2); len(kv) == 2 {
+			fmt.Fprintf(&script, " %s=%s", kv[0], host.ShellQuote(kv[1]))
+		}
+	}
+	script.WriteString("; export GENEOS_HOOK")
+	for _, e := range env {
+		script.WriteString(" " + strings.SplitN(e, "=", 2)[0])
+	}
+	fmt.Fprintf(&script, "; cd %s && %s", host.ShellQuote(dir), command)
+
+	if h.DryRunf("run %s hook %q in %s", event, command, dir) {
+		return
+	}
+	logDebug.Printf("%s: running %s hook %q", h, event, command)
+	out, err := h.RunAs(sudouser, "/bin/sh", "-c", script.String())
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s hook: %w: %s", event, err, msg)
+		}
+		return fmt.Errorf("%s hook: %w", event, err)
+	}
+	logDebug.Printf("%s: %s hook output: %s", h, event, out)
+	return
+}
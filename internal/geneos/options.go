@@ -15,6 +15,7 @@ type Options struct {
 	downloadbase string
 	downloadtype string
 	filename     string
+	parallel     int
 }
 
 type GeneosOptions func(*Options)
@@ -90,3 +91,13 @@ func UseSnapshots() GeneosOptions {
 func Filename(f string) GeneosOptions {
 	return func(d *Options) { d.filename = f }
 }
+
+// Parallel sets the maximum number of concurrent workers for loops over
+// instances. A value of zero means use the configured default.
+func Parallel(n int) GeneosOptions {
+	return func(d *Options) { d.parallel = n }
+}
+
+func (d *Options) Parallel() int {
+	return d.parallel
+}
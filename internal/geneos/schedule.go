@@ -0,0 +1,65 @@
+package geneos
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"wonderland.org/geneos/internal/utils"
+)
+
+// ScheduleActions are the commands that can be scheduled
+var ScheduleActions = []string{"start", "stop", "restart", "clean", "update"}
+
+// Schedule is a command run at the times given by a cron schedule. Args
+// are the arguments to the command, e.g. flags, TYPE and NAMEs.
+type Schedule struct {
+	Name   string   `json:"name"`
+	Cron   string   `json:"cron"`
+	Action string   `json:"action"`
+	Args   []string `json:"args,omitempty"`
+}
+
+// Validate checks the cron schedule and action of s and returns the
+// parsed schedule
+func (s Schedule) Validate() (c *utils.Cron, err error) {
+	if c, err = utils.ParseCron(s.Cron); err != nil {
+		return
+	}
+	for _, a := range ScheduleActions {
+		if s.Action == a {
+			return
+		}
+	}
+	return nil, fmt.Errorf("%q cannot be scheduled, must be one of %v: %w", s.Action, ScheduleActions, ErrInvalidArgs)
+}
+
+// ReadSchedules returns the schedules in the "schedules" setting of the
+// user configuration file
+func ReadSchedules() (schedules []Schedule, err error) {
+	v := viper.New()
+	v.SetConfigFile(UserConfigFilePath())
+	v.ReadInConfig()
+	err = v.UnmarshalKey("schedules", &schedules)
+	return
+}
+
+// WriteSchedules replaces the schedules in the user configuration file,
+// leaving other settings unchanged
+func WriteSchedules(schedules []Schedule) error {
+	v := viper.New()
+	v.SetConfigFile(UserConfigFilePath())
+	v.ReadInConfig()
+	if len(schedules) == 0 {
+		// viper cannot remove a key, so copy all the others
+		nv := viper.New()
+		for _, k := range v.AllKeys() {
+			if k != "schedules" {
+				nv.Set(k, v.Get(k))
+			}
+		}
+		nv.SetConfigFile(UserConfigFilePath())
+		return nv.WriteConfig()
+	}
+	v.Set("schedules", schedules)
+	return v.WriteConfig()
+}
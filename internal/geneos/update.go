@@ -89,12 +89,35 @@ func Update(h *host.Host, ct *Component, options ...GeneosOptions) (err error) {
 
 	}
 
+	// the update hooks are per component type and host, run in the
+	// packages directory
+	env := []string{
+		"GENEOS_TYPE=" + ct.String(),
+		"GENEOS_HOST=" + h.String(),
+		"GENEOS_BASE=" + opts.basename,
+		"GENEOS_VERSION=" + opts.version,
+		"GENEOS_PREVIOUS=" + existing,
+	}
+	if hook := ComponentHook(ct, HookPreUpdate); hook != "" {
+		if err = RunHook(h, h.SudoUser(), HookPreUpdate, hook, basedir, env); err != nil {
+			return fmt.Errorf("%s on %s not updated: %w", ct, h, err)
+		}
+	}
+
 	if err = h.Remove(basepath); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return err
 	}
 	if err = h.Symlink(opts.version, basepath); err != nil {
 		return err
 	}
-	log.Println(ct, h.Path(basepath), "updated to", opts.version)
+	if !host.DryRun {
+		log.Println(ct, h.Path(basepath), "updated to", opts.version)
+	}
+
+	if hook := ComponentHook(ct, HookPostUpdate); hook != "" {
+		if err = RunHook(h, h.SudoUser(), HookPostUpdate, hook, basedir, env); err != nil {
+			logError.Println(ct, h, err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,32 @@
+package host
+
+import (
+	"fmt"
+	"io"
+)
+
+// DryRun stops the Host methods that change files or run commands from
+// doing anything. Instead each logs what it would have done and returns
+// without an error. Methods that only read are not affected.
+var DryRun bool
+
+// DryRunf logs the change described by format and args, prefixed with
+// the host, and returns true if DryRun is set. Otherwise it does
+// nothing and returns false. Callers outside this package use it to
+// report changes they make other than through a Host.
+func (h *Host) DryRunf(format string, args ...interface{}) bool {
+	if !DryRun {
+		return false
+	}
+	log.Printf("dry run: %s: would %s", h, fmt.Sprintf(format, args...))
+	return true
+}
+
+// discard is returned by Create in dry run mode
+type discard struct {
+	io.Writer
+}
+
+func (discard) Close() error {
+	return nil
+}
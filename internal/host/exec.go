@@ -0,0 +1,44 @@
+package host
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// Run runs the command name with args on host h and returns the
+// combined stdout and stderr. Remote commands are run through an ssh
+// session and the arguments are quoted for the remote shell.
+func (h *Host) Run(name string, args ...string) (output []byte, err error) {
+	if DryRun {
+		cmd := []string{name}
+		for _, a := range args {
+			cmd = append(cmd, ShellQuote(a))
+		}
+		h.DryRunf("run %s", strings.Join(cmd, " "))
+		return
+	}
+	switch h.GetString("name") {
+	case LOCALHOST:
+		return exec.Command(name, args...).CombinedOutput()
+	default:
+		s, err := h.Dial()
+		if err != nil {
+			return nil, err
+		}
+		sess, err := s.NewSession()
+		if err != nil {
+			return nil, err
+		}
+		defer sess.Close()
+		cmd := []string{ShellQuote(name)}
+		for _, a := range args {
+			cmd = append(cmd, ShellQuote(a))
+		}
+		return sess.CombinedOutput(strings.Join(cmd, " "))
+	}
+}
+
+// ShellQuote returns s quoted for a POSIX shell
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -40,6 +40,9 @@ var (
 // we know the size of config structs is typically small, so just marshal
 // in memory
 func (h *Host) WriteConfigFile(file string, username string, perms fs.FileMode, config interface{}) (err error) {
+	if h.DryRunf("write %s", file) {
+		return
+	}
 	j, err := json.MarshalIndent(config, "", "    ")
 	if err != nil {
 		return
@@ -185,6 +188,9 @@ func copyDirEntry(fi fs.FileInfo, srcHost *Host, srcPath string, dstHost *Host,
 // at some point this should become interface based to allow other
 // remote protocols cleanly
 func (h *Host) Symlink(target, path string) (err error) {
+	if h.DryRunf("link %s to %s", path, target) {
+		return
+	}
 	switch h.GetString("name") {
 	case LOCALHOST:
 		return os.Symlink(target, path)
@@ -211,6 +217,13 @@ func (h *Host) Readlink(file string) (link string, err error) {
 }
 
 func (h *Host) MkdirAll(path string, perm os.FileMode) (err error) {
+	if DryRun {
+		// only report directories that do not already exist
+		if _, err = h.Stat(path); err != nil {
+			h.DryRunf("create directory %s", path)
+		}
+		return nil
+	}
 	switch h.GetString("name") {
 	case LOCALHOST:
 		return os.MkdirAll(path, perm)
@@ -224,6 +237,9 @@ func (h *Host) MkdirAll(path string, perm os.FileMode) (err error) {
 }
 
 func (h *Host) Chown(name string, uid, gid int) (err error) {
+	if h.DryRunf("change owner of %s to %d:%d", name, uid, gid) {
+		return
+	}
 	switch h.GetString("name") {
 	case LOCALHOST:
 		return os.Chown(name, uid, gid)
@@ -237,6 +253,9 @@ func (h *Host) Chown(name string, uid, gid int) (err error) {
 }
 
 func (h *Host) Create(path string, perms fs.FileMode) (out io.WriteCloser, err error) {
+	if h.DryRunf("create %s", path) {
+		return discard{io.Discard}, nil
+	}
 	switch h.GetString("name") {
 	case LOCALHOST:
 		var cf *os.File
@@ -266,6 +285,14 @@ func (h *Host) Create(path string, perms fs.FileMode) (out io.WriteCloser, err e
 }
 
 func (h *Host) Remove(name string) (err error) {
+	if DryRun {
+		// only report files that exist
+		if _, err = h.Lstat(name); err != nil {
+			return
+		}
+		h.DryRunf("remove %s", name)
+		return
+	}
 	switch h.GetString("name") {
 	case LOCALHOST:
 		return os.Remove(name)
@@ -279,6 +306,12 @@ func (h *Host) Remove(name string) (err error) {
 }
 
 func (h *Host) RemoveAll(name string) (err error) {
+	if DryRun {
+		if _, err = h.Lstat(name); err == nil {
+			h.DryRunf("remove %s and its contents", name)
+		}
+		return nil
+	}
 	switch h.GetString("name") {
 	case LOCALHOST:
 		return os.RemoveAll(name)
@@ -308,6 +341,9 @@ func (h *Host) RemoveAll(name string) (err error) {
 }
 
 func (h *Host) Rename(oldpath, newpath string) (err error) {
+	if h.DryRunf("rename %s to %s", oldpath, newpath) {
+		return
+	}
 	switch h.GetString("name") {
 	case LOCALHOST:
 		return os.Rename(oldpath, newpath)
@@ -393,6 +429,9 @@ func (h *Host) Glob(pattern string) (paths []string, err error) {
 }
 
 func (h *Host) WriteFile(path string, b []byte, perm os.FileMode) (err error) {
+	if h.DryRunf("write %s (%d bytes)", path, len(b)) {
+		return
+	}
 	switch h.GetString("name") {
 	case LOCALHOST:
 		return os.WriteFile(path, b, perm)
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -26,17 +27,23 @@ type Host struct {
 	// always true for LOCALHOST and ALLHOSTS
 	loaded bool
 
-	// initially, if we fail to connect to host then mark as failed
-	// as we run in single shot mode, also record error
-	//
-	// later, once we are long-running as a daemon then we can use
-	// some sort of retry mechanism, but not for now
-	// lastFailure time.Time
-	failed error
+	// if we fail to connect to host then mark as failed and record
+	// the error. in single shot mode this is permanent but when
+	// long-running as a daemon the host is retried after RetryInterval
+	failed   error
+	failedAt time.Time
+
+	// protects failed as hosts are shared between concurrent workers
+	mu sync.Mutex
 }
 
 var hosts sync.Map
 
+// RetryInterval is how long a failed host is left before connecting is
+// tried again. The default of zero means never retry, which suits
+// single shot commands.
+var RetryInterval time.Duration
+
 // this is called from cmd root
 func Init() {
 	LOCAL = Get(LOCALHOST)
@@ -63,14 +70,14 @@ func Get(name string) (c *Host) {
 		if LOCAL != nil {
 			return LOCAL
 		}
-		c = &Host{viper.New(), true, nil}
+		c = &Host{Viper: viper.New(), loaded: true}
 		c.Set("name", LOCALHOST)
 		c.GetOSReleaseEnv()
 	case ALLHOSTS:
 		if ALL != nil {
 			return ALL
 		}
-		c = &Host{viper.New(), true, nil}
+		c = &Host{Viper: viper.New(), loaded: true}
 		c.Set("name", ALLHOSTS)
 	default:
 		r, ok := hosts.Load(name)
@@ -81,7 +88,7 @@ func Get(name string) (c *Host) {
 			}
 		}
 		// or bootstrap, but NOT save a new one
-		c = &Host{viper.New(), false, nil}
+		c = &Host{Viper: viper.New()}
 		c.Set("name", name)
 		hosts.Store(name, c)
 	}
@@ -103,7 +110,25 @@ func (h *Host) Exists() bool {
 }
 
 func (h *Host) Failed() bool {
-	return h.failed != nil
+	return h.Err() != nil
+}
+
+// return the error from the first failed connection attempt, if any,
+// unless RetryInterval has passed since the failure
+func (h *Host) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.failed != nil && RetryInterval > 0 && time.Since(h.failedAt) > RetryInterval {
+		h.failed = nil
+	}
+	return h.failed
+}
+
+func (h *Host) setFailed(err error) {
+	h.mu.Lock()
+	h.failed = err
+	h.failedAt = time.Now()
+	h.mu.Unlock()
 }
 
 func (h *Host) String() string {
@@ -126,7 +151,7 @@ func (h *Host) GetOSReleaseEnv() (err error) {
 	f, err := h.ReadFile("/etc/os-release")
 	if err != nil {
 		if f, err = h.ReadFile("/usr/lib/os-release"); err != nil {
-			return fmt.Errorf("cannot open /etc/os-release or /usr/lib/os-release")
+			return fmt.Errorf("cannot open /etc/os-release or /usr/lib/os-release: %w", err)
 		}
 	}
 
@@ -209,12 +234,15 @@ func ReadConfigFile() {
 		for n, h := range hs.AllSettings() {
 			v := viper.New()
 			v.MergeConfigMap(h.(map[string]interface{}))
-			hosts.Store(n, &Host{v, true, nil})
+			hosts.Store(n, &Host{Viper: v, loaded: true})
 		}
 	}
 }
 
 func WriteConfigFile() error {
+	if LOCAL.DryRunf("write %s", UserHostsFilePath()) {
+		return nil
+	}
 	n := viper.New()
 
 	hosts.Range(func(k, v interface{}) bool {
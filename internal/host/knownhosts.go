@@ -0,0 +1,326 @@
+package host
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// UserKnownHostsFile is the known_hosts file, in the user config
+// directory, that host keys accepted through geneos are recorded in. It
+// is used together with the user's ~/.ssh/known_hosts.
+const UserKnownHostsFile = "geneos-known_hosts"
+
+// host key check outcomes
+const (
+	HostKeyKnown      = "ok"
+	HostKeyUnknown    = "unknown"
+	HostKeyChanged    = "changed"
+	HostKeyRecorded   = "recorded"
+	HostKeyNotChecked = "not checked"
+)
+
+var knownHostsLock sync.Mutex
+
+func KnownHostsFilePath() string {
+	userConfDir, err := os.UserConfigDir()
+	if err != nil {
+		logError.Fatalln(err)
+	}
+	return filepath.Join(userConfDir, UserKnownHostsFile)
+}
+
+// knownHostsFiles returns the known_hosts files that exist, the user's
+// own first
+func knownHostsFiles() (files []string) {
+	var paths []string
+	if homedir, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(homedir, userSSHdir, "known_hosts"))
+	}
+	paths = append(paths, KnownHostsFilePath())
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			files = append(files, p)
+		}
+	}
+	return
+}
+
+// checkHostKey checks key for dest, in the form HOST:PORT, against the
+// known_hosts files. It returns nil if the key is known or a
+// *knownhosts.KeyError, with an empty Want if the host is not known at
+// all.
+func checkHostKey(dest string, remote net.Addr, key ssh.PublicKey) error {
+	files := knownHostsFiles()
+	if len(files) == 0 {
+		return &knownhosts.KeyError{}
+	}
+	cb, err := knownhosts.New(files...)
+	if err != nil {
+		return err
+	}
+	if remote == nil {
+		remote = &net.TCPAddr{IP: net.IPv4zero}
+	}
+	return cb(dest, remote, key)
+}
+
+func hostKeyStatus(err error) string {
+	var keyErr *knownhosts.KeyError
+	switch {
+	case err == nil:
+		return HostKeyKnown
+	case errors.As(err, &keyErr) && len(keyErr.Want) == 0:
+		return HostKeyUnknown
+	case errors.As(err, &keyErr):
+		return HostKeyChanged
+	default:
+		return ""
+	}
+}
+
+// matchHost returns true if any of the hosts from a known_hosts line is
+// addr, either in plain text or hashed. Patterns are not matched.
+func matchHost(hosts []string, addr string) bool {
+	for _, h := range hosts {
+		if h == addr {
+			return true
+		}
+		// hashed entries are |1|base64(salt)|base64(hmac-sha1(salt, host))
+		if p := strings.Split(h, "|"); len(p) == 4 && p[0] == "" && p[1] == "1" {
+			salt, err1 := base64.StdEncoding.DecodeString(p[2])
+			hash, err2 := base64.StdEncoding.DecodeString(p[3])
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			mac := hmac.New(sha1.New, salt)
+			mac.Write([]byte(addr))
+			if hmac.Equal(mac.Sum(nil), hash) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// KnownHostKey is a host key recorded in a known_hosts file
+type KnownHostKey struct {
+	Key    ssh.PublicKey
+	File   string
+	Marker string
+}
+
+// recordedHostKeys returns the keys recorded for dest, in the form
+// HOST:PORT, in all the known_hosts files
+func recordedHostKeys(dest string) (keys []KnownHostKey) {
+	addr := knownhosts.Normalize(dest)
+	for _, file := range knownHostsFiles() {
+		rest, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for len(rest) > 0 {
+			var marker string
+			var hosts []string
+			var key ssh.PublicKey
+			marker, hosts, key, _, rest, err = ssh.ParseKnownHosts(rest)
+			if err != nil {
+				// skip the bad line
+				if i := bytes.IndexByte(rest, '\n'); i != -1 {
+					rest = rest[i+1:]
+					continue
+				}
+				break
+			}
+			if matchHost(hosts, addr) {
+				keys = append(keys, KnownHostKey{key, file, marker})
+			}
+		}
+	}
+	return
+}
+
+// recordHostKey records key for dest, in the form HOST:PORT, in the
+// geneos known_hosts file, replacing any keys already recorded there
+// for the same host and port
+func recordHostKey(dest string, key ssh.PublicKey) (err error) {
+	path := KnownHostsFilePath()
+	if LOCAL.DryRunf("record %s host key %s for %s in %s", key.Type(), ssh.FingerprintSHA256(key), dest, path) {
+		return
+	}
+
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	addr := knownhosts.Normalize(dest)
+	var lines []string
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if _, hosts, _, _, _, err := ssh.ParseKnownHosts([]byte(line)); err == nil && matchHost(hosts, addr) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, knownhosts.Line([]string{addr}, key))
+
+	if err = os.MkdirAll(filepath.Dir(path), 0775); err != nil {
+		return
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
+
+// HostKey is the host key of one SSH hop on the way to a remote host,
+// either a jump host or the remote host itself
+type HostKey struct {
+	Dest     string
+	Address  string
+	Jump     bool
+	Strict   string
+	Key      ssh.PublicKey
+	Recorded []KnownHostKey
+	Status   string
+}
+
+// sshHops returns the connections needed to reach h, the jump hosts
+// first
+func (h *Host) sshHops() (hops []sshDest, err error) {
+	d := h.sshDest()
+	if hops, err = parseProxyJump(h.GetString("proxyjump"), d.user); err != nil {
+		return
+	}
+	return append(hops, d), nil
+}
+
+func newHostKey(d sshDest, jump bool) HostKey {
+	dest := net.JoinHostPort(d.hostname, d.port)
+	return HostKey{
+		Dest:     d.String(),
+		Address:  knownhosts.Normalize(dest),
+		Jump:     jump,
+		Strict:   strings.ToLower(d.strictHostKeyChecking),
+		Recorded: recordedHostKeys(dest),
+	}
+}
+
+// HostKeys returns the recorded host keys for h and any jump hosts,
+// without connecting to them
+func (h *Host) HostKeys() (keys []HostKey, err error) {
+	hops, err := h.sshHops()
+	if err != nil {
+		return
+	}
+	for i, d := range hops {
+		keys = append(keys, newHostKey(d, i < len(hops)-1))
+	}
+	return
+}
+
+var errHostKeyFetched = errors.New("host key fetched")
+
+// fetchHostKey returns the host key presented by d, connecting through
+// via if it is not nil, without authenticating
+func fetchHostKey(via *ssh.Client, d sshDest) (key ssh.PublicKey, remote net.Addr, err error) {
+	dest := net.JoinHostPort(d.hostname, d.port)
+	var conn net.Conn
+	if via == nil {
+		conn, err = net.DialTimeout("tcp", dest, 5*time.Second)
+	} else {
+		conn, err = via.Dial("tcp", dest)
+	}
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	config := &ssh.ClientConfig{
+		User: d.user,
+		HostKeyCallback: func(hostname string, r net.Addr, k ssh.PublicKey) error {
+			key, remote = k, r
+			return errHostKeyFetched
+		},
+		Timeout: 5 * time.Second,
+	}
+	if _, _, _, err = ssh.NewClientConn(conn, dest, config); key != nil {
+		err = nil
+	}
+	return
+}
+
+// CheckHostKeys connects to h, through any jump hosts, and checks the
+// host key presented at each hop against the known_hosts files. If
+// record is not nil it is called for keys that are unknown or have
+// changed and, if it returns true, the key is recorded in the geneos
+// known_hosts file, replacing any recorded there for the same address.
+// Checking stops at a jump host with a key that is not accepted. Hops
+// with stricthostkeychecking set to "no" are not checked.
+func (h *Host) CheckHostKeys(record func(k HostKey) (bool, error)) (keys []HostKey, err error) {
+	hops, err := h.sshHops()
+	if err != nil {
+		return
+	}
+
+	var via *ssh.Client
+	var key string
+	for i, d := range hops {
+		k := newHostKey(d, i < len(hops)-1)
+		if k.Strict == "no" || k.Strict == "off" {
+			k.Status = HostKeyNotChecked
+		} else {
+			var remote net.Addr
+			if k.Key, remote, err = fetchHostKey(via, d); err != nil {
+				err = fmt.Errorf("%s: %w", d, err)
+				return
+			}
+			k.Status = hostKeyStatus(checkHostKey(net.JoinHostPort(d.hostname, d.port), remote, k.Key))
+			if k.Status == "" {
+				err = fmt.Errorf("%s: cannot check host key", d)
+				return
+			}
+			if k.Status != HostKeyKnown && record != nil {
+				var ok bool
+				if ok, err = record(k); err != nil {
+					return
+				}
+				if ok {
+					if err = recordHostKey(net.JoinHostPort(d.hostname, d.port), k.Key); err != nil {
+						return
+					}
+					k.Status = HostKeyRecorded
+				}
+			}
+		}
+		keys = append(keys, k)
+
+		if !k.Jump {
+			break
+		}
+		if k.Status != HostKeyKnown && k.Status != HostKeyRecorded && k.Status != HostKeyNotChecked {
+			return
+		}
+		key = sshKey(key, d)
+		if via, err = dialCached(via, key, d); err != nil {
+			err = fmt.Errorf("jump host %s: %w", d, err)
+			return
+		}
+	}
+	return
+}
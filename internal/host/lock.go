@@ -0,0 +1,123 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// a lock file older than this is assumed to have been left behind by a
+// process that has gone away. Locks are only held for short periods and
+// this must be less than the timeouts callers use, so that a crashed
+// holder does not make them all time out.
+var lockStale = 20 * time.Second
+
+// Lock takes an advisory lock on host h by exclusively creating the
+// file path, waiting up to timeout for any other holder to release it.
+// The returned unlock function removes the file.
+func (h *Host) Lock(path string, timeout time.Duration) (unlock func(), err error) {
+	// nothing is changed in dry run mode so there is nothing to lock
+	if DryRun {
+		return func() {}, nil
+	}
+	deadline := time.Now().Add(timeout)
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%d@%s\n", os.Getpid(), hostname)
+
+	// held is set once the lock is known to exist, so that a create
+	// that failed for another reason, e.g. permissions or a missing
+	// directory, is returned at once instead of retried until timeout
+	var held bool
+	for {
+		var f io.WriteCloser
+		if f, err = h.createExclusive(path); err == nil {
+			_, err = io.WriteString(f, owner)
+			f.Close()
+			if err != nil {
+				h.Remove(path)
+				return
+			}
+			logDebug.Println("locked", h, path)
+			return func() {
+				if err := h.Remove(path); err != nil {
+					logError.Println("cannot remove lock file:", err)
+				}
+				logDebug.Println("unlocked", h, path)
+			}, nil
+		}
+
+		if errors.Is(err, fs.ErrExist) {
+			held = true
+		}
+
+		// sftp servers do not report why an exclusive create failed,
+		// so check for an existing lock directly
+		st, serr := h.Stat(path)
+		if serr != nil {
+			// if the lock has been seen then the holder released it
+			// between the two calls, so try again
+			if held && errors.Is(serr, fs.ErrNotExist) && time.Now().Before(deadline) {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return
+		}
+		held = true
+		if time.Since(time.Unix(st.Mtime, 0)) > lockStale {
+			h.breakStaleLock(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			b, _ := h.ReadFile(path)
+			return nil, fmt.Errorf("timed out waiting for lock %s on %s held by %s", path, h, strings.TrimSpace(string(b)))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// breakStaleLock removes the lock file path if it is stale. Waiters that
+// find the same stale lock take turns through a second lock file,
+// path.break, and check again before removing it, so that a lock just
+// taken by another waiter is never removed.
+func (h *Host) breakStaleLock(path string) {
+	brk := path + ".break"
+	f, err := h.createExclusive(brk)
+	if err != nil {
+		// another waiter is removing the lock, unless it went away
+		// while doing so
+		if st, err := h.Stat(brk); err == nil && time.Since(time.Unix(st.Mtime, 0)) > lockStale {
+			h.Remove(brk)
+		}
+		time.Sleep(100 * time.Millisecond)
+		return
+	}
+	f.Close()
+	defer h.Remove(brk)
+
+	st, err := h.Stat(path)
+	if err != nil || time.Since(time.Unix(st.Mtime, 0)) <= lockStale {
+		return
+	}
+	b, _ := h.ReadFile(path)
+	log.Printf("removing stale lock %s on %s held by %s", path, h, strings.TrimSpace(string(b)))
+	h.Remove(path)
+}
+
+func (h *Host) createExclusive(path string) (out io.WriteCloser, err error) {
+	switch h.GetString("name") {
+	case LOCALHOST:
+		return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0664)
+	default:
+		var s *sftp.Client
+		if s, err = h.DialSFTP(); err != nil {
+			return
+		}
+		return s.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	}
+}
@@ -1,6 +1,8 @@
 package host
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
@@ -9,9 +11,9 @@ import (
 	"time"
 
 	"github.com/pkg/sftp"
+	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
-	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 const userSSHdir = ".ssh"
@@ -19,18 +21,44 @@ const userSSHdir = ".ssh"
 var sshSessions sync.Map
 var sftpSessions sync.Map
 
-var privateKeys = ""
+// per destination locks for session set-up
+var sessionLocks sync.Map
 
-// load any/all the known private keys with no passphrase
-func readSSHkeys(homedir string) (signers []ssh.Signer) {
-	for _, keyfile := range strings.Split(privateKeys, ",") {
-		path := filepath.Join(homedir, userSSHdir, keyfile)
+func sessionLock(key string) *sync.Mutex {
+	l, _ := sessionLocks.LoadOrStore(key, &sync.Mutex{})
+	return l.(*sync.Mutex)
+}
+
+// load the private keys for d, either the identity files set or the
+// ones named in the privatekeys setting in the user's .ssh directory.
+// Keys that need a passphrase are decrypted using passphrasefile or by
+// prompting for it.
+func readSSHkeys(homedir string, d sshDest) (signers []ssh.Signer) {
+	var paths []string
+	for _, id := range d.identityFiles {
+		paths = append(paths, sshExpand(id, d.hostname, d.user))
+	}
+	if len(paths) == 0 {
+		for _, keyfile := range strings.Split(viper.GetString("privatekeys"), ",") {
+			paths = append(paths, filepath.Join(homedir, userSSHdir, keyfile))
+		}
+	}
+
+	for _, path := range paths {
 		key, err := os.ReadFile(path)
 		if err != nil {
+			if len(d.identityFiles) > 0 {
+				logDebug.Println("cannot read identity file", path, err)
+			}
 			continue
 		}
 		signer, err := ssh.ParsePrivateKey(key)
+		var passErr *ssh.PassphraseMissingError
+		if errors.As(err, &passErr) {
+			signer, err = passphraseSigner(path, key, d.passphraseFile)
+		}
 		if err != nil {
+			logDebug.Println("cannot load private key from", path, err)
 			continue
 		}
 		logDebug.Println("loaded private key from", path)
@@ -39,7 +67,95 @@ func readSSHkeys(homedir string) (signers []ssh.Signer) {
 	return
 }
 
-func sshConnect(dest, user string) (client *ssh.Client, err error) {
+// sshDest is the destination of one SSH connection, either a remote
+// host or a jump host on the way to one
+type sshDest struct {
+	user                  string
+	hostname              string
+	port                  string
+	identityFiles         []string
+	passphraseFile        string
+	strictHostKeyChecking string
+	passwordAuth          bool
+	passwordFile          string
+}
+
+// String returns the destination as USER@HOST:PORT, which is also the
+// key for cached connections
+func (d sshDest) String() string {
+	return d.user + "@" + d.hostname + ":" + d.port
+}
+
+// sshDest returns the connection settings for h. Identity files and
+// host key checking not set for h are taken from the user's ssh config
+// for the hostname.
+func (h *Host) sshDest() (d sshDest) {
+	d = sshDest{
+		user:                  h.GetString("username"),
+		hostname:              h.GetString("hostname"),
+		port:                  h.GetString("port"),
+		identityFiles:         h.GetStringSlice("identityfile"),
+		passphraseFile:        h.GetString("passphrasefile"),
+		strictHostKeyChecking: h.GetString("stricthostkeychecking"),
+		passwordAuth:          h.GetBool("passwordauth") || h.IsSet("passwordfile"),
+		passwordFile:          h.GetString("passwordfile"),
+	}
+	if d.passphraseFile == "" {
+		d.passphraseFile = viper.GetString("sshpassphrasefile")
+	}
+	if !h.IsSet("identityfile") {
+		d.identityFiles = SSHConfigAll(d.hostname, "IdentityFile")
+	}
+	if d.strictHostKeyChecking == "" {
+		d.strictHostKeyChecking = SSHConfig(d.hostname, "StrictHostKeyChecking")
+	}
+	return
+}
+
+// hostKeyCallback returns the host key check for the stricthostkeychecking
+// setting, which has the same meaning as the ssh(1) option of the same
+// name except that "ask" is treated as "yes" as there is no prompting
+// while connecting. Keys are checked against both the user's
+// known_hosts file and the geneos one, which is where new keys are
+// recorded.
+func hostKeyCallback(strict string) (khCallback ssh.HostKeyCallback, err error) {
+	strict = strings.ToLower(strict)
+	switch strict {
+	case "no", "off":
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			logDebug.Printf("not checking host key %s for %s", ssh.FingerprintSHA256(key), hostname)
+			return nil
+		}, nil
+	case "", "yes", "ask", "accept-new":
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) (err error) {
+			err = checkHostKey(hostname, remote, key)
+			switch hostKeyStatus(err) {
+			case HostKeyKnown:
+				return
+			case HostKeyUnknown:
+				if strict != "accept-new" {
+					return fmt.Errorf("host key %s for %s is not known, check and record it with 'geneos host keys -R'", ssh.FingerprintSHA256(key), hostname)
+				}
+				if err = recordHostKey(hostname, key); err != nil {
+					return
+				}
+				log.Printf("added %s key %s for %s to %s", key.Type(), ssh.FingerprintSHA256(key), hostname, KnownHostsFilePath())
+				return
+			case HostKeyChanged:
+				return fmt.Errorf("host key for %s has changed and is now %s, check it with 'geneos host keys -V'", hostname, ssh.FingerprintSHA256(key))
+			default:
+				return
+			}
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid stricthostkeychecking %q: %w", strict, ErrInvalidArgs)
+	}
+}
+
+// sshConnect opens an SSH connection to d. If via is not nil then the
+// connection is tunnelled through that client, which is how jump hosts
+// are traversed.
+func sshConnect(via *ssh.Client, d sshDest) (client *ssh.Client, err error) {
 	var khCallback ssh.HostKeyCallback
 	var authmethods []ssh.AuthMethod
 	var signers []ssh.Signer
@@ -52,10 +168,7 @@ func sshConnect(dest, user string) (client *ssh.Client, err error) {
 	}
 
 	if khCallback == nil {
-		k := filepath.Join(homedir, userSSHdir, "known_hosts")
-		khCallback, err = knownhosts.New(k)
-		if err != nil {
-			logDebug.Println("cannot load ssh known_hosts file, ssh will not be available.")
+		if khCallback, err = hostKeyCallback(d.strictHostKeyChecking); err != nil {
 			return
 		}
 	}
@@ -73,43 +186,222 @@ func sshConnect(dest, user string) (client *ssh.Client, err error) {
 	}
 
 	if signers == nil {
-		signers = readSSHkeys(homedir)
+		signers = readSSHkeys(homedir, d)
 	}
 
-	if agentClient != nil {
-		authmethods = append(authmethods, ssh.PublicKeysCallback(agentClient.Signers))
+	// agent and file keys must be offered by a single method as the
+	// client does not try a second "publickey" method
+	if agentClient != nil || len(signers) > 0 {
+		authmethods = append(authmethods, ssh.PublicKeysCallback(func() (s []ssh.Signer, err error) {
+			if agentClient != nil {
+				if s, err = agentClient.Signers(); err != nil {
+					logDebug.Println("cannot get keys from ssh-agent:", err)
+				}
+			}
+			return append(s, signers...), nil
+		}))
 	}
-	if signers == nil {
-		authmethods = append(authmethods, ssh.PublicKeys(signers...))
+	if d.passwordAuth {
+		authmethods = append(authmethods, passwordAuthMethods(d)...)
 	}
 
 	config := &ssh.ClientConfig{
-		User:            user,
+		User:            d.user,
 		Auth:            authmethods,
 		HostKeyCallback: khCallback,
 		Timeout:         5 * time.Second,
 	}
-	return ssh.Dial("tcp", dest, config)
+	dest := net.JoinHostPort(d.hostname, d.port)
+	if via == nil {
+		if client, err = ssh.Dial("tcp", dest, config); err != nil {
+			// do not reuse a password that may be wrong
+			passwords.Delete(d.String())
+		}
+		return
+	}
+
+	conn, err := via.Dial("tcp", dest)
+	if err != nil {
+		return
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, dest, config)
+	if err != nil {
+		passwords.Delete(d.String())
+		conn.Close()
+		return
+	}
+	return ssh.NewClient(c, chans, reqs), nil
 }
 
-func (h *Host) Dial() (s *ssh.Client, err error) {
-	if h.failed != nil {
-		err = h.failed
+// parseProxyJump parses a comma separated list of jump hosts, each in
+// the form [USER@]HOST[:PORT], in the order they are to be traversed.
+// As with ssh(1), HOST can be an alias in the user's ssh config, which
+// is used for any HostName, User, Port, IdentityFile and
+// StrictHostKeyChecking settings. USER otherwise defaults to
+// defaultuser and PORT to 22.
+func parseProxyJump(proxyjump, defaultuser string) (jumps []sshDest, err error) {
+	for _, j := range strings.Split(proxyjump, ",") {
+		j = strings.TrimPrefix(strings.TrimSpace(j), "ssh://")
+		if j == "" {
+			continue
+		}
+		var user string
+		hostport := j
+		if i := strings.LastIndex(j, "@"); i != -1 {
+			user, hostport = j[:i], j[i+1:]
+			if user == "" {
+				return nil, fmt.Errorf("invalid proxyjump host %q: %w", j, ErrInvalidArgs)
+			}
+		}
+		alias, port, err := net.SplitHostPort(hostport)
+		if err != nil {
+			alias = hostport
+		}
+		alias = strings.Trim(alias, "[]")
+		if alias == "" {
+			return nil, fmt.Errorf("invalid proxyjump host %q: %w", j, ErrInvalidArgs)
+		}
+
+		d := sshDest{
+			user:                  user,
+			hostname:              alias,
+			port:                  port,
+			identityFiles:         SSHConfigAll(alias, "IdentityFile"),
+			passphraseFile:        viper.GetString("sshpassphrasefile"),
+			strictHostKeyChecking: SSHConfig(alias, "StrictHostKeyChecking"),
+		}
+		if v := sshConfigHostName(alias); v != "" {
+			d.hostname = v
+		}
+		if d.port == "" {
+			d.port = SSHConfig(alias, "Port")
+		}
+		if d.port == "" {
+			d.port = "22"
+		}
+		if d.user == "" {
+			d.user = SSHConfig(alias, "User")
+		}
+		if d.user == "" {
+			d.user = defaultuser
+		}
+		jumps = append(jumps, d)
+	}
+	return
+}
+
+// ProxyJump returns the jump hosts, if any, that connections to h are
+// tunnelled through, in the form USER@HOST:PORT
+func (h *Host) ProxyJump() (jumps []string, err error) {
+	j, err := parseProxyJump(h.GetString("proxyjump"), h.GetString("username"))
+	if err != nil {
 		return
 	}
-	dest := h.GetString("hostname") + ":" + h.GetString("port")
-	user := h.GetString("username")
-	val, ok := sshSessions.Load(user + "@" + dest)
+	for _, jh := range j {
+		jumps = append(jumps, jh.String())
+	}
+	return
+}
+
+// sshKey returns the key of the cached client for d when reached
+// through the jump hosts with the key via, which is empty for a direct
+// connection. The key is the chain of USER@HOST:PORT destinations so
+// that hosts with the same address behind different jump hosts do not
+// share a client, while a jump host that is also a configured host,
+// with no jump hosts of its own, shares one connection.
+func sshKey(via string, d sshDest) string {
+	if via == "" {
+		return d.String()
+	}
+	return via + "," + d.String()
+}
+
+// sshKey returns the key of the cached client for h, including any jump
+// hosts
+func (h *Host) sshKey() string {
+	d := h.sshDest()
+	var key string
+	jumps, _ := parseProxyJump(h.GetString("proxyjump"), d.user)
+	for _, j := range jumps {
+		key = sshKey(key, j)
+	}
+	return sshKey(key, d)
+}
+
+// dialCached returns the cached client for key, opening a new one to d
+// through via if there is none or if the cached one has gone away.
+func dialCached(via *ssh.Client, key string, d sshDest) (s *ssh.Client, err error) {
+
+	// serialise connections to the same destination so that concurrent
+	// callers share one client
+	l := sessionLock("ssh:" + key)
+	l.Lock()
+	defer l.Unlock()
+
+	val, ok := sshSessions.Load(key)
 	if ok {
 		s = val.(*ssh.Client)
-	} else {
-		s, err = sshConnect(dest, user)
+		// a long-running process must check that the cached
+		// connection has not gone away
+		if RetryInterval > 0 {
+			if _, _, err = s.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				logDebug.Println("host connection lost", key, err)
+				s.Close()
+				sshSessions.Delete(key)
+				// including any sftp clients run through sudo
+				sftpSessions.Range(func(k, _ interface{}) bool {
+					if k == key || strings.HasPrefix(k.(string), key+"+") {
+						sftpSessions.Delete(k)
+					}
+					return true
+				})
+				ok = false
+			}
+		}
+	}
+	if !ok {
+		s, err = sshConnect(via, d)
 		if err != nil {
-			h.failed = err
 			return
 		}
-		logDebug.Println("host opened", h.GetString("name"), dest, user)
-		sshSessions.Store(user+"@"+dest, s)
+		logDebug.Println("ssh connection opened", key)
+		sshSessions.Store(key, s)
+	}
+	return
+}
+
+func (h *Host) Dial() (s *ssh.Client, err error) {
+	if err = h.Err(); err != nil {
+		return
+	}
+	d := h.sshDest()
+
+	jumps, err := parseProxyJump(h.GetString("proxyjump"), d.user)
+	if err != nil {
+		h.setFailed(err)
+		return
+	}
+
+	// each hop is dialled through the one before, reusing any
+	// connections already open
+	var via *ssh.Client
+	var key string
+	for _, j := range jumps {
+		if j.String() == d.String() {
+			err = fmt.Errorf("host %s cannot be its own jump host", h)
+			h.setFailed(err)
+			return
+		}
+		key = sshKey(key, j)
+		if via, err = dialCached(via, key, j); err != nil {
+			err = fmt.Errorf("jump host %s: %w", j, err)
+			h.setFailed(err)
+			return
+		}
+	}
+
+	if s, err = dialCached(via, sshKey(key, d), d); err != nil {
+		h.setFailed(err)
 	}
 	return
 }
@@ -117,50 +409,74 @@ func (h *Host) Dial() (s *ssh.Client, err error) {
 func (h *Host) Close() {
 	h.CloseSFTP()
 
-	dest := h.GetString("hostname") + ":" + h.GetString("port")
-	user := h.GetString("username")
-	val, ok := sshSessions.Load(user + "@" + dest)
+	key := h.sshKey()
+	val, ok := sshSessions.Load(key)
 	if ok {
 		s := val.(*ssh.Client)
 		s.Close()
-		sshSessions.Delete(user + "@" + dest)
+		sshSessions.Delete(key)
+	}
+}
+
+// sftpKey returns the key for the cached sftp client of h, which is
+// that of the ssh connection plus any sudouser
+func (h *Host) sftpKey() string {
+	key := h.sshKey()
+	if u := h.SudoUser(); u != "" {
+		key += "+" + u
 	}
+	return key
 }
 
 // succeed or fatal
 func (h *Host) DialSFTP() (f *sftp.Client, err error) {
-	if h.failed != nil {
-		err = h.failed
+	if err = h.Err(); err != nil {
 		return
 	}
-	dest := h.GetString("hostname") + ":" + h.GetString("port")
-	user := h.GetString("username")
-	val, ok := sftpSessions.Load(user + "@" + dest)
+	key := h.sftpKey()
+
+	if RetryInterval > 0 {
+		// check the underlying connection, which also drops any
+		// stale sftp client
+		if _, err = h.Dial(); err != nil {
+			return
+		}
+	}
+
+	l := sessionLock("sftp:" + key)
+	l.Lock()
+	defer l.Unlock()
+
+	val, ok := sftpSessions.Load(key)
 	if ok {
 		f = val.(*sftp.Client)
 	} else {
 		var s *ssh.Client
 		if s, err = h.Dial(); err != nil {
-			h.failed = err
+			h.setFailed(err)
 			return
 		}
-		if f, err = sftp.NewClient(s); err != nil {
-			h.failed = err
+		if u := h.SudoUser(); u != "" {
+			f, err = h.sudoSFTP(s, u)
+		} else {
+			f, err = sftp.NewClient(s)
+		}
+		if err != nil {
+			h.setFailed(err)
 			return
 		}
 		logDebug.Println("remote opened", h.GetString("name"))
-		sftpSessions.Store(user+"@"+dest, f)
+		sftpSessions.Store(key, f)
 	}
 	return
 }
 
 func (h *Host) CloseSFTP() {
-	dest := h.GetString("hostname") + ":" + h.GetString("port")
-	user := h.GetString("username")
-	val, ok := sftpSessions.Load(user + "@" + dest)
+	key := h.sftpKey()
+	val, ok := sftpSessions.Load(key)
 	if ok {
 		f := val.(*sftp.Client)
 		f.Close()
-		sftpSessions.Delete(user + "@" + dest)
+		sftpSessions.Delete(key)
 	}
 }
@@ -0,0 +1,181 @@
+package host
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+	"wonderland.org/geneos/internal/utils"
+)
+
+// passphrases and passwords are asked for at most once per run, and
+// prompts are serialised as connections are made concurrently
+var promptLock sync.Mutex
+var passphrases []string
+var passwords sync.Map
+
+// canPrompt returns true if passphrases and passwords can be read from
+// the terminal
+func canPrompt() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// readSecretFile returns the trimmed contents of path, like
+// utils.ReadPasswordFile but returning an error instead of exiting
+func readSecretFile(path string) (string, error) {
+	b, err := os.ReadFile(sshExpand(path, "", ""))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// decryptKey returns a signer for the passphrase protected private key
+// pemBytes read from path. Passphrases already given for other keys are
+// tried first, then the one in passphraseFile, if set, and finally the
+// user is prompted if there is a terminal.
+func decryptKey(path string, pemBytes []byte, passphraseFile string) (signer ssh.Signer, err error) {
+	promptLock.Lock()
+	defer promptLock.Unlock()
+
+	for _, p := range passphrases {
+		if signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(p)); err == nil {
+			return
+		}
+	}
+
+	var p string
+	switch {
+	case passphraseFile != "":
+		if p, err = readSecretFile(passphraseFile); err != nil {
+			return nil, fmt.Errorf("cannot read passphrase for %s: %w", path, err)
+		}
+	case canPrompt():
+		p = utils.ReadPasswordPrompt(fmt.Sprintf("Enter passphrase for key '%s':", path))
+	default:
+		return nil, fmt.Errorf("private key %s is passphrase protected and there is no passphrase file or terminal", path)
+	}
+
+	if signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(p)); err != nil {
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			err = fmt.Errorf("incorrect passphrase for %s", path)
+		}
+		return
+	}
+	passphrases = append(passphrases, p)
+	return
+}
+
+// encryptedSigner is a passphrase protected private key with a known
+// public key. The key is only decrypted, and so the passphrase only asked
+// for, when the server accepts the public key.
+type encryptedSigner struct {
+	path           string
+	pemBytes       []byte
+	passphraseFile string
+	pub            ssh.PublicKey
+	once           sync.Once
+	signer         ssh.Signer
+	err            error
+}
+
+func (e *encryptedSigner) PublicKey() ssh.PublicKey {
+	return e.pub
+}
+
+func (e *encryptedSigner) decrypt() error {
+	e.once.Do(func() {
+		e.signer, e.err = decryptKey(e.path, e.pemBytes, e.passphraseFile)
+		if e.err != nil {
+			logError.Println(e.err)
+		}
+	})
+	return e.err
+}
+
+func (e *encryptedSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	if err := e.decrypt(); err != nil {
+		return nil, err
+	}
+	return e.signer.Sign(rand, data)
+}
+
+// SignWithAlgorithm allows RSA keys to use the SHA-2 signature
+// algorithms, which most servers now require
+func (e *encryptedSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	if err := e.decrypt(); err != nil {
+		return nil, err
+	}
+	if as, ok := e.signer.(ssh.AlgorithmSigner); ok {
+		return as.SignWithAlgorithm(rand, data, algorithm)
+	}
+	return e.signer.Sign(rand, data)
+}
+
+// passphraseSigner returns a signer for a passphrase protected private
+// key. If the public key is available in path.pub then decryption is
+// deferred until it is needed, otherwise it is done now.
+func passphraseSigner(path string, pemBytes []byte, passphraseFile string) (ssh.Signer, error) {
+	if b, err := os.ReadFile(path + ".pub"); err == nil {
+		if pub, _, _, _, err := ssh.ParseAuthorizedKey(b); err == nil {
+			return &encryptedSigner{
+				path:           path,
+				pemBytes:       pemBytes,
+				passphraseFile: passphraseFile,
+				pub:            pub,
+			}, nil
+		}
+	}
+	return decryptKey(path, pemBytes, passphraseFile)
+}
+
+// sshPassword returns the password for d, from the password file if set
+// or by prompting once if there is a terminal
+func sshPassword(d sshDest) (password string, err error) {
+	if d.passwordFile != "" {
+		return readSecretFile(d.passwordFile)
+	}
+
+	promptLock.Lock()
+	defer promptLock.Unlock()
+
+	if p, ok := passwords.Load(d.String()); ok {
+		return p.(string), nil
+	}
+	if !canPrompt() {
+		return "", fmt.Errorf("no password file or terminal for %s", d)
+	}
+	password = utils.ReadPasswordPrompt(fmt.Sprintf("%s@%s's password:", d.user, d.hostname))
+	passwords.Store(d.String(), password)
+	return
+}
+
+// passwordAuthMethods returns the password and keyboard-interactive
+// authentication methods for d. Keyboard-interactive questions that are
+// not echoed are answered with the password.
+func passwordAuthMethods(d sshDest) []ssh.AuthMethod {
+	return []ssh.AuthMethod{
+		ssh.PasswordCallback(func() (string, error) {
+			return sshPassword(d)
+		}),
+		ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) (answers []string, err error) {
+			for i, q := range questions {
+				if echos[i] {
+					return nil, fmt.Errorf("unsupported keyboard-interactive question %q", q)
+				}
+				p, err := sshPassword(d)
+				if err != nil {
+					return nil, err
+				}
+				answers = append(answers, p)
+			}
+			return
+		}),
+	}
+}
@@ -0,0 +1,198 @@
+package host
+
+import (
+	"bytes"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kevinburke/ssh_config"
+)
+
+const systemSSHConfig = "/etc/ssh/ssh_config"
+
+// sshConfigFile is a parsed OpenSSH client configuration file
+type sshConfigFile struct {
+	path   string
+	config *ssh_config.Config
+	warned sync.Once
+}
+
+var sshConfigs []*sshConfigFile
+var sshConfigOnce sync.Once
+
+// loadSSHConfig parses the user's ~/.ssh/config and the system-wide
+// ssh_config, in that order, once. Missing files are ignored and files
+// that cannot be parsed are skipped. Match blocks, which the parser
+// does not support, are removed with a warning so that the rest of the
+// file is still used.
+func loadSSHConfig() {
+	sshConfigOnce.Do(func() {
+		var files []string
+		if homedir, err := os.UserHomeDir(); err == nil {
+			files = append(files, filepath.Join(homedir, userSSHdir, "config"))
+		}
+		files = append(files, systemSSHConfig)
+
+		for _, file := range files {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			b, removed := removeMatchBlocks(b)
+			if removed {
+				logError.Printf("%s: Match blocks are not supported and are ignored", file)
+			}
+			c, err := ssh_config.DecodeBytes(b)
+			if err != nil {
+				logError.Printf("cannot parse %s, ignoring: %s", file, err)
+				continue
+			}
+			logDebug.Println("loaded ssh config from", file)
+			sshConfigs = append(sshConfigs, &sshConfigFile{path: file, config: c})
+		}
+	})
+}
+
+// removeMatchBlocks returns the ssh config file contents b with any
+// Match blocks blanked out, keeping the line numbering. A Match block
+// runs until the next Host or Match line.
+func removeMatchBlocks(b []byte) (out []byte, removed bool) {
+	lines := bytes.Split(b, []byte("\n"))
+	inMatch := false
+	for i, line := range lines {
+		fields := strings.FieldsFunc(string(line), func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '='
+		})
+		if len(fields) > 0 {
+			switch strings.ToLower(fields[0]) {
+			case "match":
+				inMatch, removed = true, true
+			case "host":
+				inMatch = false
+			}
+		}
+		if inMatch {
+			lines[i] = nil
+		}
+	}
+	return bytes.Join(lines, []byte("\n")), removed
+}
+
+// lookup calls fn with the parsed configuration. The parser panics on
+// Match directives, for example in included files, in which case a
+// warning is logged once for the file and false is returned so that
+// the caller can go on to the next file.
+func (f *sshConfigFile) lookup(fn func(c *ssh_config.Config)) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			f.warned.Do(func() {
+				logError.Printf("%s: %v, settings from it may be missing", f.path, r)
+			})
+			ok = false
+		}
+	}()
+	fn(f.config)
+	return true
+}
+
+// SSHConfig returns the first value of key for the host alias from the
+// OpenSSH client configuration files, in the same way as ssh(1), or an
+// empty string if it is not set. Keys are not case sensitive.
+func SSHConfig(alias, key string) (value string) {
+	loadSSHConfig()
+	for _, f := range sshConfigs {
+		f.lookup(func(c *ssh_config.Config) {
+			value, _ = c.Get(alias, key)
+		})
+		if value != "" {
+			return
+		}
+	}
+	return
+}
+
+// SSHConfigAll returns all the values of key for the host alias from
+// the OpenSSH client configuration files, for keys such as IdentityFile
+// that can be given more than once.
+func SSHConfigAll(alias, key string) (values []string) {
+	loadSSHConfig()
+	for _, f := range sshConfigs {
+		var v []string
+		f.lookup(func(c *ssh_config.Config) {
+			v, _ = c.GetAll(alias, key)
+		})
+		values = append(values, v...)
+	}
+	return
+}
+
+// sshExpand expands the leading '~' and the %% tokens supported by ssh(1)
+// for IdentityFile and similar settings: %d (local home directory), %h
+// (remote hostname), %r (remote user), %u (local user) and %%.
+func sshExpand(s, hostname, remoteuser string) string {
+	homedir, _ := os.UserHomeDir()
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		s = homedir + s[1:]
+	}
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var localuser string
+	if u, err := user.Current(); err == nil {
+		localuser = u.Username
+	}
+	r := strings.NewReplacer(
+		"%%", "%",
+		"%d", homedir,
+		"%h", hostname,
+		"%r", remoteuser,
+		"%u", localuser,
+	)
+	return r.Replace(s)
+}
+
+// sshConfigHostName returns the HostName for alias, with any %h
+// replaced by the alias, or an empty string if it is not set
+func sshConfigHostName(alias string) string {
+	if v := SSHConfig(alias, "HostName"); v != "" {
+		return strings.NewReplacer("%%", "%", "%h", alias).Replace(v)
+	}
+	return ""
+}
+
+// SSHHostConfig returns the connection settings for the host alias from
+// the OpenSSH client configuration files, for those that are set. The
+// settings are named as for remote hosts: hostname, port, username,
+// proxyjump, identityfile (a list, unexpanded) and
+// stricthostkeychecking.
+func SSHHostConfig(alias string) (settings map[string]interface{}) {
+	settings = make(map[string]interface{})
+	if v := sshConfigHostName(alias); v != "" {
+		settings["hostname"] = v
+	}
+	if v := SSHConfig(alias, "Port"); v != "" {
+		settings["port"] = v
+	}
+	if v := SSHConfig(alias, "User"); v != "" {
+		settings["username"] = v
+	}
+	if v := SSHConfig(alias, "ProxyJump"); v != "" && !strings.EqualFold(v, "none") {
+		settings["proxyjump"] = v
+	}
+	var ids []string
+	for _, id := range SSHConfigAll(alias, "IdentityFile") {
+		if !strings.EqualFold(id, "none") {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > 0 {
+		settings["identityfile"] = ids
+	}
+	if v := SSHConfig(alias, "StrictHostKeyChecking"); v != "" {
+		settings["stricthostkeychecking"] = strings.ToLower(v)
+	}
+	return
+}
@@ -0,0 +1,146 @@
+package host
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// the usual places for the OpenSSH sftp-server, tried in order when a
+// remote host has no sftpserver setting
+var sftpServers = []string{
+	"/usr/lib/openssh/sftp-server",
+	"/usr/libexec/openssh/sftp-server",
+	"/usr/lib/ssh/sftp-server",
+	"/usr/libexec/sftp-server",
+}
+
+// SudoUser returns the sudouser setting for h, the account that file
+// operations on the host are run as through sudo, or an empty string if
+// it is not set or is the login user. It is always empty for localhost.
+func (h *Host) SudoUser() string {
+	if h.GetString("name") == LOCALHOST {
+		return ""
+	}
+	if u := h.GetString("sudouser"); u != h.GetString("username") {
+		return u
+	}
+	return ""
+}
+
+// SudoAs returns the account that an instance on h configured to run as
+// username is controlled as, through sudo, or an empty string if that is
+// the login user. An empty username means the host's sudouser. Using
+// sudo must be allowed for the host, by the sudo or sudouser settings,
+// or by the instance, with allow, otherwise an error is returned.
+// Localhost never uses sudo.
+func (h *Host) SudoAs(username string, allow bool) (sudouser string, err error) {
+	if h.GetString("name") == LOCALHOST {
+		return "", nil
+	}
+	if username == "" {
+		username = h.GetString("sudouser")
+	}
+	login := h.GetString("username")
+	if username == "" || username == login {
+		return "", nil
+	}
+	if !allow && !h.GetBool("sudo") && !h.IsSet("sudouser") {
+		return "", fmt.Errorf("cannot run remote process as a different user (%q != %q) without sudo, set sudo for the host or instance", login, username)
+	}
+	return username, nil
+}
+
+// SudoCommand returns cmd, a command line for the remote shell, prefixed
+// to run as sudouser through sudo. cmd is returned unchanged if sudouser
+// is empty. sudo must not ask for a password.
+func SudoCommand(sudouser, cmd string) string {
+	if sudouser == "" {
+		return cmd
+	}
+	return "sudo -n -u " + ShellQuote(sudouser) + " -- " + cmd
+}
+
+// RunAs is like Run but, on remote hosts, the command is run as
+// sudouser through sudo unless sudouser is empty
+func (h *Host) RunAs(sudouser, name string, args ...string) (output []byte, err error) {
+	if sudouser == "" || h.GetString("name") == LOCALHOST {
+		return h.Run(name, args...)
+	}
+	cmd := []string{ShellQuote(name)}
+	for _, a := range args {
+		cmd = append(cmd, ShellQuote(a))
+	}
+	cmdline := SudoCommand(sudouser, strings.Join(cmd, " "))
+	if h.DryRunf("run %s", cmdline) {
+		return
+	}
+	s, err := h.Dial()
+	if err != nil {
+		return nil, err
+	}
+	sess, err := s.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close()
+	return sess.CombinedOutput(cmdline)
+}
+
+// sudoSFTP returns an sftp client over s that runs sftp-server as
+// sudouser through sudo, so that file operations have the permissions
+// of that account. The sftpserver setting for h is the path to the
+// server, otherwise the usual locations are tried.
+func (h *Host) sudoSFTP(s *ssh.Client, sudouser string) (f *sftp.Client, err error) {
+	var server string
+	if p := h.GetString("sftpserver"); p != "" {
+		server = "exec " + ShellQuote(p)
+	} else {
+		var paths []string
+		for _, p := range sftpServers {
+			paths = append(paths, ShellQuote(p))
+		}
+		server = fmt.Sprintf(`for p in %s; do [ -x "$p" ] && exec "$p"; done; echo "sftp-server not found" >&2; exit 127`, strings.Join(paths, " "))
+	}
+
+	sess, err := s.NewSession()
+	if err != nil {
+		return
+	}
+	w, err := sess.StdinPipe()
+	if err != nil {
+		sess.Close()
+		return
+	}
+	r, err := sess.StdoutPipe()
+	if err != nil {
+		sess.Close()
+		return
+	}
+	var stderr strings.Builder
+	sess.Stderr = &stderr
+	if err = sess.Start(SudoCommand(sudouser, "/bin/sh -c "+ShellQuote(server))); err != nil {
+		sess.Close()
+		return
+	}
+	if f, err = sftp.NewClientPipe(r, w); err != nil {
+		// wait for sudo to exit so that all of stderr is read
+		w.Close()
+		sess.Wait()
+		sess.Close()
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("sftp as %s: %s", sudouser, msg)
+		} else {
+			err = fmt.Errorf("sftp as %s: %w", sudouser, err)
+		}
+		return
+	}
+	// the session ends when the client is closed
+	go func() {
+		sess.Wait()
+		sess.Close()
+	}()
+	return
+}
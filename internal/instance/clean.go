@@ -5,11 +5,17 @@ import (
 
 	"github.com/spf13/viper"
 	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
 )
 
-func Clean(c geneos.Instance, options ...geneos.GeneosOptions) (err error) {
+// Clean removes the files in the component clean list from the
+// instance directory. If the geneos.Restart() option is set then the
+// purge list is also removed and a running instance is stopped first
+// and restarted afterwards.
+func Clean(c geneos.Instance, options ...geneos.GeneosOptions) (result Result) {
 	var stopped bool
 
+	result = NewResult(c, "clean")
 	opts := geneos.EvalOptions(options...)
 
 	cleanlist := viper.GetString(c.Type().CleanList)
@@ -17,34 +23,43 @@ func Clean(c geneos.Instance, options ...geneos.GeneosOptions) (err error) {
 
 	if !opts.Restart() {
 		if cleanlist != "" {
-			if err = RemovePaths(c, cleanlist); err == nil {
-				logDebug.Println(c, "cleaned")
+			if err := RemovePaths(c, cleanlist); err != nil {
+				return result.Fail(err)
 			}
+			logDebug.Println(c, "cleaned")
 		}
 		return
 	}
 
-	if _, err = GetPID(c); err == os.ErrProcessDone {
+	if _, err := GetPID(c); err == os.ErrProcessDone {
 		stopped = false
-	} else if err = Stop(c, false); err != nil {
-		return
+	} else if r := Stop(c, false); r.Outcome == Failed {
+		return result.Fail(r.Err)
 	} else {
 		stopped = true
 	}
 
 	if cleanlist != "" {
-		if err = RemovePaths(c, cleanlist); err != nil {
-			return
+		if err := RemovePaths(c, cleanlist); err != nil {
+			return result.Fail(err)
 		}
 	}
 	if purgelist != "" {
-		if err = RemovePaths(c, purgelist); err != nil {
-			return
+		if err := RemovePaths(c, purgelist); err != nil {
+			return result.Fail(err)
 		}
 	}
 	logDebug.Println(c, "fully cleaned")
 	if stopped {
-		err = Start(c)
+		r := Start(c)
+		result.PID = r.PID
+		if r.Outcome == Failed {
+			return result.Fail(r.Err)
+		}
+		if host.DryRun {
+			return result.Set(OK, "would be fully cleaned and %s", r.Message)
+		}
+		return result.Set(OK, "fully cleaned and %s", r.Message)
 	}
 	return
 }
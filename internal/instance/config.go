@@ -27,6 +27,7 @@ type ExtraConfigValues struct {
 	Variables  VarValues
 	Types      StringSliceValues
 	Keys       StringSliceValues
+	Labels     LabelValues
 }
 
 // return the KEY from "[TYPE:]KEY=VALUE"
@@ -238,12 +239,18 @@ func WriteConfig(c geneos.Instance) (err error) {
 		}
 		nv.SetFs(sftpfs.New(client))
 	}
+	if c.Host().DryRunf("write %s", file) {
+		return nil
+	}
 	logDebug.Printf("writing config for %s as %q", c, file)
 	return nv.WriteConfigAs(file)
 }
 
 func WriteConfigValues(c geneos.Instance, values map[string]interface{}) error {
 	file := ConfigPathWithExt(c, "json")
+	if c.Host().DryRunf("write %s", file) {
+		return nil
+	}
 	nv := viper.New()
 	for k, v := range values {
 		nv.Set(k, v)
@@ -402,6 +409,14 @@ func SetExtendedValues(c geneos.Instance, x ExtraConfigValues) (changed bool) {
 		c.V().Set("variables", vars)
 	}
 
+	if len(x.Labels) > 0 {
+		labels := c.V().GetStringMapString("labels")
+		for k, v := range x.Labels {
+			labels[k] = v
+		}
+		c.V().Set("labels", labels)
+	}
+
 	return
 }
 
@@ -496,6 +511,27 @@ func (i *GatewayValues) Type() string {
 	return "HOSTNAME:PORT"
 }
 
+// label - name=value, names are not case sensitive
+type LabelValues map[string]string
+
+func (i *LabelValues) String() string {
+	return ""
+}
+
+func (i *LabelValues) Set(value string) error {
+	e := strings.SplitN(value, "=", 2)
+	if len(e) != 2 || strings.TrimSpace(e[0]) == "" || strings.ContainsAny(e[0], "=!, ") {
+		logError.Printf("invalid label %q, must be NAME=VALUE", value)
+		return geneos.ErrInvalidArgs
+	}
+	(*i)[strings.ToLower(e[0])] = e[1]
+	return nil
+}
+
+func (i *LabelValues) Type() string {
+	return "NAME=VALUE"
+}
+
 // attribute - name=value
 type StringSliceValues []string
 
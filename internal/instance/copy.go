@@ -79,12 +79,12 @@ func CopyInstance(ct *geneos.Component, srcname, dstname string, remove bool) (e
 	dst.Unload()
 
 	if _, err = GetPID(src); err != os.ErrProcessDone {
-		if err = Stop(src, false); err == nil {
+		if r := Stop(src, false); r.Outcome != Failed {
 			stopped = true
 			// defer a call to restart the original if not "done"
 			defer func(c geneos.Instance) {
 				if !done {
-					Start(c)
+					log.Println(Start(c))
 				}
 			}(src)
 		} else {
@@ -93,7 +93,7 @@ func CopyInstance(ct *geneos.Component, srcname, dstname string, remove bool) (e
 	}
 
 	// now a full clean
-	if err = Clean(src, geneos.Restart(true)); err != nil {
+	if err = Clean(src, geneos.Restart(true)).Err; err != nil {
 		return
 	}
 
@@ -118,8 +118,10 @@ func CopyInstance(ct *geneos.Component, srcname, dstname string, remove bool) (e
 				// once we are done, try to delete old instance
 				logDebug.Println("removing old instance", srcname)
 				srcrem.RemoveAll(srchome)
-				log.Println(srcname, "moved to", dst)
-			} else {
+				if !host.DryRun {
+					log.Println(srcname, "moved to", dst)
+				}
+			} else if !host.DryRun {
 				log.Println(srcname, "copied to", dstname)
 			}
 		} else {
@@ -135,6 +137,11 @@ func CopyInstance(ct *geneos.Component, srcname, dstname string, remove bool) (e
 
 	// fetch a new port if hosts are different and port is already used
 	if src.Host() != dr {
+		var unlock func()
+		if unlock, err = LockPorts(dr); err != nil {
+			return
+		}
+		defer unlock()
 		srcport := src.V().GetInt64("port")
 		dstports := GetPorts(dr)
 		if _, ok := dstports[uint16(srcport)]; ok {
@@ -155,14 +162,18 @@ func CopyInstance(ct *geneos.Component, srcname, dstname string, remove bool) (e
 	}
 
 	// src.Unload()
-	if err = realdst.Rebuild(false); err != nil && err != geneos.ErrNotSupported {
+	if err = Rebuild(realdst, false); err != nil && err != geneos.ErrNotSupported {
 		logDebug.Println(err)
 		return
 	}
 
 	done = true
 	if stopped {
-		return Start(realdst)
+		r := Start(realdst)
+		if r.Err == nil {
+			log.Println(r)
+		}
+		return r.Err
 	}
 	return nil
 }
@@ -0,0 +1,184 @@
+package instance
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+)
+
+// the default licd port used by gateways without a "licdport" setting
+const defaultLicdPort = 7041
+
+// Dependencies returns the instances in cs that c depends on, in the
+// order of cs. These are:
+//
+//   - a gateway depends on the licd on its "licdhost" and "licdport",
+//     defaulting to localhost and 7041
+//   - an instance with a "gateways" setting, e.g. a SAN, depends on the
+//     gateways it connects to
+//   - a webserver depends on all gateways
+//   - any instance depends on the instances named in its "depends"
+//     setting, a list of [TYPE:]NAME[@HOST] separated by commas or spaces
+//
+// Instances not in cs are ignored, so dependencies only order the
+// instances being acted on.
+func Dependencies(c geneos.Instance, cs []geneos.Instance) (deps []geneos.Instance) {
+	for _, d := range cs {
+		if d != c && dependsOn(c, d) {
+			deps = append(deps, d)
+		}
+	}
+	return
+}
+
+// dependsOn returns true if c depends on d
+func dependsOn(c, d geneos.Instance) bool {
+	ctype, dtype := c.Type().String(), d.Type().String()
+
+	switch {
+	case ctype == "gateway" && dtype == "licd":
+		licdhost := c.V().GetString("licdhost")
+		if licdhost == "" {
+			licdhost = "localhost"
+		}
+		licdport := c.V().GetInt("licdport")
+		if licdport == 0 {
+			licdport = defaultLicdPort
+		}
+		if d.V().GetInt("port") == licdport && sameHost(c, licdhost, d) {
+			return true
+		}
+	case ctype == "webserver" && dtype == "gateway":
+		return true
+	case dtype == "gateway":
+		for gwhost, gwport := range c.V().GetStringMapString("gateways") {
+			if d.V().GetString("port") == gwport && sameHost(c, gwhost, d) {
+				return true
+			}
+		}
+	}
+
+	for _, name := range strings.FieldsFunc(c.V().GetString("depends"), func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		ct, n, h := SplitName(name, c.Host())
+		if n == d.Name() && h == d.Host() && (ct == nil || ct == d.Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameHost returns true if hostname, as used in the configuration of c
+// to connect to another instance, is the host of d
+func sameHost(c geneos.Instance, hostname string, d geneos.Instance) bool {
+	switch hostname {
+	case "localhost", "127.0.0.1", "::1":
+		return c.Host() == d.Host()
+	}
+	h := d.Host()
+	if hostname == h.String() || hostname == h.GetString("hostname") {
+		return true
+	}
+	if h == host.LOCAL {
+		if local, err := os.Hostname(); err == nil && (hostname == local || strings.HasPrefix(local, hostname+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stages orders cs into stages so that each instance is in a later
+// stage than all of the instances in cs it depends on. The order of cs
+// is kept within each stage. Instances in a dependency loop are logged
+// and put in a final stage.
+func Stages(cs []geneos.Instance) (stages [][]geneos.Instance) {
+	deps := make(map[geneos.Instance][]geneos.Instance, len(cs))
+	for _, c := range cs {
+		deps[c] = Dependencies(c, cs)
+	}
+
+	done := make(map[geneos.Instance]bool, len(cs))
+	remaining := cs
+	for len(remaining) > 0 {
+		var stage, next []geneos.Instance
+	INSTANCES:
+		for _, c := range remaining {
+			for _, d := range deps[c] {
+				if !done[d] {
+					next = append(next, c)
+					continue INSTANCES
+				}
+			}
+			stage = append(stage, c)
+		}
+		if len(stage) == 0 {
+			log.Println("dependency loop between", next)
+			return append(stages, next)
+		}
+		for _, c := range stage {
+			done[c] = true
+		}
+		stages = append(stages, stage)
+		remaining = next
+	}
+	return
+}
+
+// ForAllStages is like ForAllResults but calls fn for the instances in
+// dependency order, see Stages, with the instances in each stage called
+// concurrently. If reverse is true, e.g. to stop instances, the stages
+// are called in reverse order. Otherwise, after each stage, wait for any
+// instances in it that others depend on to be listening on their port,
+// for up to the "dependencywait" setting. Results are returned in the
+// order fn was called.
+func ForAllStages(ct *geneos.Component, fn func(geneos.Instance, []string) Result, args []string, params []string, reverse bool, options ...geneos.GeneosOptions) (results Results, err error) {
+	opts := geneos.EvalOptions(options...)
+	cs, err := match(ct, args, params)
+	if err != nil {
+		return
+	}
+
+	stages := Stages(cs)
+	if reverse {
+		for i, j := 0, len(stages)-1; i < j; i, j = i+1, j-1 {
+			stages[i], stages[j] = stages[j], stages[i]
+		}
+	}
+
+	needed := make(map[geneos.Instance]bool)
+	if !reverse {
+		for _, c := range cs {
+			for _, d := range Dependencies(c, cs) {
+				needed[d] = true
+			}
+		}
+	}
+
+	for n, stage := range stages {
+		logDebug.Printf("stage %d: %v", n, stage)
+		stageResults := make(Results, len(stage))
+		Run(stage, func(i int, c geneos.Instance) error {
+			stageResults[i] = fn(c, params)
+			return nil
+		}, opts.Parallel())
+		results = append(results, stageResults...)
+
+		if n == len(stages)-1 {
+			break
+		}
+		Run(stage, func(i int, c geneos.Instance) error {
+			if !needed[c] || stageResults[i].Outcome == Failed || stageResults[i].Outcome == Skipped {
+				return nil
+			}
+			if err := WaitReady(c, viper.GetDuration("dependencywait")); err != nil {
+				log.Println(c, err)
+			}
+			return nil
+		}, opts.Parallel())
+	}
+	return results, results.Err()
+}
@@ -0,0 +1,191 @@
+package instance
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+)
+
+// Errors is the aggregated result of ForAll, one entry per failed
+// instance in the order the instances were matched
+type Errors []error
+
+func (e Errors) Error() string {
+	var s []string
+	for _, err := range e {
+		s = append(s, err.Error())
+	}
+	return strings.Join(s, "\n")
+}
+
+// InstanceError wraps the error returned for a specific instance
+type InstanceError struct {
+	Instance geneos.Instance
+	Err      error
+}
+
+func (e InstanceError) Error() string {
+	return e.Instance.String() + ": " + e.Err.Error()
+}
+
+func (e InstanceError) Unwrap() error {
+	return e.Err
+}
+
+// given a component type and a slice of args, call the function for
+// each matching instance
+//
+// calls are made concurrently, up to the "parallel" setting (or the
+// geneos.Parallel() option) at once and limited per host by the host's
+// "parallel" setting, defaulting to the global "hostparallel". errors
+// are collected and returned as Errors in match order, except
+// os.ErrProcessDone and geneos.ErrNotSupported which are ignored.
+//
+// fn must not write to shared state without locking and any output it
+// produces will not be ordered. Use geneos.Parallel(1) for serial calls.
+func ForAll(ct *geneos.Component, fn func(geneos.Instance, []string) error, args []string, params []string, options ...geneos.GeneosOptions) (err error) {
+	opts := geneos.EvalOptions(options...)
+	cs, err := match(ct, args, params)
+	if err != nil {
+		return
+	}
+
+	errs := Run(cs, func(_ int, c geneos.Instance) error {
+		return fn(c, params)
+	}, opts.Parallel())
+
+	var result Errors
+	for i, err := range errs {
+		if err != nil && !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, geneos.ErrNotSupported) {
+			result = append(result, InstanceError{cs[i], err})
+		}
+	}
+	if len(result) > 0 {
+		return result
+	}
+	return nil
+}
+
+// ForAllResults is like ForAll but fn returns a Result for each
+// instance. The results are returned in match order along with an error
+// which is os.ErrNotExist if nothing matched or otherwise the Errors of
+// any failed results.
+func ForAllResults(ct *geneos.Component, fn func(geneos.Instance, []string) Result, args []string, params []string, options ...geneos.GeneosOptions) (results Results, err error) {
+	opts := geneos.EvalOptions(options...)
+	cs, err := match(ct, args, params)
+	if err != nil {
+		return
+	}
+
+	results = make(Results, len(cs))
+	Run(cs, func(i int, c geneos.Instance) error {
+		// each call writes only to its own slot
+		results[i] = fn(c, params)
+		return nil
+	}, opts.Parallel())
+
+	return results, results.Err()
+}
+
+// MatchArgs returns all the instances of type ct matching args, in the
+// same order that ForAll uses, or os.ErrNotExist if there are none
+func MatchArgs(ct *geneos.Component, args []string, params []string) ([]geneos.Instance, error) {
+	return match(ct, args, params)
+}
+
+// match returns all the instances of type ct matching args, in order.
+// An empty args matches all instances.
+func match(ct *geneos.Component, args []string, params []string) (cs []geneos.Instance, err error) {
+	n := 0
+	logDebug.Println("args, params", args, params)
+	// if args is empty, get all matching instances this allows internal
+	// calls with an empty arg list without having to do the parsargs()
+	// dance
+	if len(args) == 0 {
+		args = AllNames(host.ALL, ct)
+	}
+	for _, name := range args {
+		m := MatchAll(ct, name)
+		if len(m) == 0 {
+			log.Println("no match for", name)
+			continue
+		}
+		n++
+		cs = append(cs, m...)
+	}
+	if n == 0 {
+		return nil, os.ErrNotExist
+	}
+	return
+}
+
+// Run calls fn with the index and value of each instance in cs using
+// up to workers concurrent goroutines, respecting per-host limits, and
+// returns a slice of errors in the same order as cs. If workers is
+// zero or less then the "parallel" setting is used.
+func Run(cs []geneos.Instance, fn func(int, geneos.Instance) error, workers int) (errs []error) {
+	errs = make([]error, len(cs))
+
+	if workers < 1 {
+		workers = viper.GetInt("parallel")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if workers == 1 || len(cs) == 1 {
+		for i, c := range cs {
+			errs[i] = fn(i, c)
+		}
+		return
+	}
+
+	// per host semaphores, created before starting so no locking needed
+	hostslots := make(map[*host.Host]chan struct{})
+	for _, c := range cs {
+		h := c.Host()
+		if _, ok := hostslots[h]; ok {
+			continue
+		}
+		limit := HostParallel(h)
+		if limit < 1 || limit > workers {
+			limit = workers
+		}
+		hostslots[h] = make(chan struct{}, limit)
+	}
+	slots := make(chan struct{}, workers)
+
+	var wg sync.WaitGroup
+	for i, c := range cs {
+		wg.Add(1)
+		go func(i int, c geneos.Instance) {
+			defer wg.Done()
+			// take a host slot first so that we do not hold a global
+			// slot while waiting on a busy host
+			hs := hostslots[c.Host()]
+			hs <- struct{}{}
+			slots <- struct{}{}
+			errs[i] = fn(i, c)
+			<-slots
+			<-hs
+		}(i, c)
+	}
+	wg.Wait()
+	return
+}
+
+// HostParallel returns the maximum number of concurrent actions allowed
+// against host h. The local host is only limited by the global setting.
+func HostParallel(h *host.Host) int {
+	if h == host.LOCAL {
+		return 0
+	}
+	if h.IsSet("parallel") {
+		return h.GetInt("parallel")
+	}
+	return viper.GetInt("hostparallel")
+}
@@ -60,9 +60,10 @@ var Gateway = geneos.Component{
 		"gatewayname={{.name}}",
 	},
 	GlobalSettings: map[string]string{
-		"GatewayPortRange": "7039,7100-",
-		"GatewayCleanList": "*.old:*.history",
-		"GatewayPurgeList": "gateway.log:gateway.txt:gateway.snooze:gateway.user_assignment:licences.cache:cache/:database/",
+		"GatewayPortRange":   "7039,7100-",
+		"GatewayCleanList":   "*.old:*.history",
+		"GatewayPurgeList":   "gateway.log:gateway.txt:gateway.snooze:gateway.user_assignment:licences.cache:cache/:database/",
+		"GatewayStopTimeout": "60s",
 	},
 	Directories: []string{
 		"packages/gateway",
@@ -71,6 +72,7 @@ var Gateway = geneos.Component{
 		"gateway/gateway_config",
 		"gateway/templates",
 	},
+	ReloadSignal: syscall.SIGUSR1,
 }
 
 type Gateways instance.Instance
@@ -327,7 +329,7 @@ func (g *Gateways) Command() (args, env []string) {
 }
 
 func (g *Gateways) Reload(params []string) (err error) {
-	return instance.Signal(g, syscall.SIGUSR1)
+	return instance.Signal(g, g.Type().ReloadSignal)
 }
 
 // create a gateway key file for secure passwords as per
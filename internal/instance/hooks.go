@@ -0,0 +1,57 @@
+package instance
+
+import (
+	"fmt"
+
+	"wonderland.org/geneos/internal/geneos"
+)
+
+// Hook returns the hook command for event for c, the instance setting
+// "hooks.EVENT" or else the global "hooks.TYPE.EVENT", or an empty
+// string if there is none
+func Hook(c geneos.Instance, event string) string {
+	if hook := c.V().GetString("hooks." + event); hook != "" {
+		return hook
+	}
+	return geneos.ComponentHook(c.Type(), event)
+}
+
+// RunHook runs the hook for event, if any, in the home directory of c on
+// its host, through sudo as the user c runs as if it is started that
+// way. The environment describes the instance in GENEOS_TYPE,
+// GENEOS_NAME, GENEOS_HOST, GENEOS_HOME and GENEOS_PORT, plus the hook
+// name in GENEOS_HOOK and any values in env.
+func RunHook(c geneos.Instance, event string, env ...string) (err error) {
+	hook := Hook(c, event)
+	if hook == "" {
+		return
+	}
+	env = append([]string{
+		"GENEOS_TYPE=" + c.Type().String(),
+		"GENEOS_NAME=" + c.Name(),
+		"GENEOS_HOST=" + c.Host().String(),
+		"GENEOS_HOME=" + c.Home(),
+		fmt.Sprintf("GENEOS_PORT=%d", c.V().GetInt("port")),
+	}, env...)
+	sudouser, err := sudoUser(c)
+	if err != nil {
+		return
+	}
+	return geneos.RunHook(c.Host(), sudouser, event, hook, c.Home(), env)
+}
+
+// Rebuild rebuilds the configuration files of c, running the
+// "prerebuild" and "postrebuild" hooks around it. A failing
+// "prerebuild" hook stops the rebuild.
+func Rebuild(c geneos.Instance, initial bool) (err error) {
+	if err = RunHook(c, geneos.HookPreRebuild); err != nil {
+		return
+	}
+	if err = c.Rebuild(initial); err != nil {
+		return
+	}
+	if err = RunHook(c, geneos.HookPostRebuild); err != nil {
+		log.Println(c, err)
+	}
+	return nil
+}
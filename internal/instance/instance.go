@@ -38,6 +38,34 @@ var (
 	logError = logger.Error
 )
 
+// GetPID returns the PID of the process of instance c or
+// os.ErrProcessDone if it is not running.
+//
+// The PID file written by Start is checked first, and used if the
+// process is still running with the recorded start time and command
+// line. Otherwise, for example if the process was started by another
+// tool or restarted by systemd, fall back to scanning all processes. A
+// PID file that does not match is replaced, or removed if the instance
+// is not running, so that it is not checked again on each call.
+func GetPID(c geneos.Instance) (pid int, err error) {
+	if dryRunStopped(c) {
+		return 0, os.ErrProcessDone
+	}
+	if pid, err = pidFromFile(c); err == nil {
+		return
+	}
+	stale := !errors.Is(err, fs.ErrNotExist)
+	pid, err = scanPID(c)
+	if stale && !host.DryRun {
+		if err == nil {
+			writePIDFile(c, pid)
+		} else {
+			removePIDFile(c)
+		}
+	}
+	return
+}
+
 // locate a process instance
 //
 // the component type must be part of the basename of the executable and
@@ -46,9 +74,8 @@ var (
 //
 // walk the /proc directory (local or remote) and find the matching pid
 // this is subject to races, but not much we can do
-func GetPID(c geneos.Instance) (pid int, err error) {
+func scanPID(c geneos.Instance) (pid int, err error) {
 	var pids []int
-	binary := c.V().GetString("binary")
 
 	// safe to ignore error as it can only be bad pattern,
 	// which means no matches to range over
@@ -67,38 +94,85 @@ func GetPID(c geneos.Instance) (pid int, err error) {
 			// process may disappear by this point, ignore error
 			continue
 		}
-		args := bytes.Split(data, []byte("\000"))
-		execfile := filepath.Base(string(args[0]))
-		switch c.Type() {
-		case geneos.ParseComponentName("webserver"):
-			var wdOK, jarOK bool
-			if execfile != "java" {
-				continue
+		if matchCmdline(c, data) {
+			return pid, nil
+		}
+	}
+	return 0, os.ErrProcessDone
+}
+
+// matchCmdline returns true if the NUL separated command line cmdline,
+// from /proc/N/cmdline, is that of the process for instance c.
+//
+// Instances of different types can have the same name and some types
+// share a binary, so as well as the name an argument that only the
+// type passes must be present. Gateways are started with the setup
+// file in the instance home and Self-Announcing Netprobes with
+// "-listenip none", which a Netprobe or FA2 never has.
+func matchCmdline(c geneos.Instance, cmdline []byte) bool {
+	args := bytes.Split(cmdline, []byte("\000"))
+	execfile := filepath.Base(string(args[0]))
+	switch c.Type() {
+	case geneos.ParseComponentName("webserver"):
+		var wdOK, jarOK bool
+		if execfile != "java" {
+			return false
+		}
+		for _, arg := range args[1:] {
+			if string(arg) == "-Dworking.directory="+c.Home() {
+				wdOK = true
 			}
-			for _, arg := range args[1:] {
-				if string(arg) == "-Dworking.directory="+c.Home() {
-					wdOK = true
-				}
-				if strings.HasSuffix(string(arg), "geneos-web-server.jar") {
-					jarOK = true
-				}
-				if wdOK && jarOK {
-					return
-				}
+			if strings.HasSuffix(string(arg), "geneos-web-server.jar") {
+				jarOK = true
 			}
-		default:
-			if strings.HasPrefix(execfile, binary) {
-				for _, arg := range args[1:] {
-					// very simplistic - we look for a bare arg that matches the instance name
-					if string(arg) == c.Name() {
-						// found
-						return
-					}
-				}
+			if wdOK && jarOK {
+				return true
+			}
+		}
+	default:
+		if !strings.HasPrefix(execfile, c.V().GetString("binary")) {
+			return false
+		}
+		var nameOK bool
+		for _, arg := range args[1:] {
+			// very simplistic - we look for a bare arg that matches the instance name
+			if string(arg) == c.Name() {
+				nameOK = true
+				break
+			}
+		}
+		if !nameOK {
+			return false
+		}
+		switch c.Type() {
+		case geneos.ParseComponentName("gateway"):
+			return hasArgs(args, "-setup", filepath.Join(c.Home(), "gateway.setup.xml"))
+		case geneos.ParseComponentName("san"):
+			return hasArgs(args, "-listenip", "none")
+		case geneos.ParseComponentName("netprobe"), geneos.ParseComponentName("fa2"):
+			return !hasArgs(args, "-listenip", "none")
+		}
+		return true
+	}
+	return false
+}
+
+// hasArgs returns true if want appears, in order, as consecutive
+// arguments in args
+func hasArgs(args [][]byte, want ...string) bool {
+	for i := 0; i+len(want) <= len(args); i++ {
+		match := true
+		for j, w := range want {
+			if string(args[i+j]) != w {
+				match = false
+				break
 			}
 		}
+		if match {
+			return true
+		}
 	}
-	return 0, os.ErrProcessDone
+	return false
 }
 
 func GetPIDInfo(c geneos.Instance) (pid int, uid uint32, gid uint32, mtime int64, err error) {
@@ -179,7 +253,9 @@ func RemovePaths(c geneos.Instance, paths string) (err error) {
 				logError.Println(err)
 				continue
 			}
-			log.Printf("removed %s", c.Host().Path(f))
+			if !host.DryRun {
+				log.Printf("removed %s", c.Host().Path(f))
+			}
 		}
 	}
 	return
@@ -200,12 +276,25 @@ func LogFile(c geneos.Instance) (logfile string) {
 	return
 }
 
+// sudoUser returns the account that the remote instance c is run and
+// signalled as through sudo, or an empty string if it is the login user
+// for the host. This is the "user" setting for c or, if that is not set,
+// the "sudouser" for the host. Unless the host allows sudo the instance
+// must have "sudo" set.
+func sudoUser(c geneos.Instance) (string, error) {
+	return c.Host().SudoAs(c.V().GetString("user"), c.V().GetBool("sudo"))
+}
+
 func Signal(c geneos.Instance, signal syscall.Signal) (err error) {
 	pid, err := GetPID(c)
 	if err != nil {
 		return os.ErrProcessDone
 	}
 
+	if c.Host().DryRunf("send %s to %s PID %d", SignalName(signal), c, pid) {
+		return nil
+	}
+
 	if c.Host() == host.LOCAL {
 		proc, _ := os.FindProcess(pid)
 		if err = proc.Signal(signal); err != nil && !errors.Is(err, syscall.EEXIST) {
@@ -216,17 +305,12 @@ func Signal(c geneos.Instance, signal syscall.Signal) (err error) {
 		return nil
 	}
 
-	rem, err := c.Host().Dial()
+	// if sudo is not allowed the signal is sent as the login user
+	sudouser, err := sudoUser(c)
 	if err != nil {
-		logError.Fatalln(err)
+		logDebug.Println(c, err)
 	}
-	sess, err := rem.NewSession()
-	if err != nil {
-		logError.Fatalln(err)
-	}
-
-	output, err := sess.CombinedOutput(fmt.Sprintf("kill -s %d %d", signal, pid))
-	sess.Close()
+	output, err := c.Host().RunAs(sudouser, "kill", "-s", fmt.Sprint(int(signal)), fmt.Sprint(pid))
 	if err != nil {
 		log.Printf("%s FAILED to send signal %d: %s %q", c, signal, err, output)
 		return
@@ -285,15 +369,59 @@ func Match(ct *geneos.Component, name string) (c geneos.Instance, err error) {
 	return
 }
 
+// IsPattern returns true if the instance name part of name, without any
+// TYPE: prefix or @HOST suffix, is a pattern. A name starting with '~'
+// is a regular expression and a name containing any of '*', '?' or '['
+// is a glob pattern.
+func IsPattern(name string) bool {
+	_, local, _ := SplitName(name, host.ALL)
+	return strings.HasPrefix(local, "~") || strings.ContainsAny(local, "*?[")
+}
+
+// NameMatcher returns a function that matches instance names against
+// pattern, which is either a plain name, a glob pattern as for
+// filepath.Match or, with a '~' prefix, an unanchored regular
+// expression. Plain names must match exactly.
+func NameMatcher(pattern string) (matches func(string) bool, err error) {
+	switch {
+	case strings.HasPrefix(pattern, "~"):
+		re, err := regexp.Compile(pattern[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+		}
+		return re.MatchString, nil
+	case strings.ContainsAny(pattern, "*?["):
+		if _, err = filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+		}
+		return func(name string) bool {
+			ok, _ := filepath.Match(pattern, name)
+			return ok
+		}, nil
+	default:
+		return func(name string) bool {
+			return name == pattern
+		}, nil
+	}
+}
+
 // construct and return a slice of a/all component types that have
-// a matching name
+// a matching name, which may be a pattern, see NameMatcher
 func MatchAll(ct *geneos.Component, name string) (c []geneos.Instance) {
-	_, local, r := SplitName(name, host.ALL)
+	nct, local, r := SplitName(name, host.ALL)
 	if !r.Exists() {
 		logDebug.Printf("host %s not loaded", r)
 		return
 	}
 
+	// a TYPE: prefix on the name limits matches to that type
+	if nct != nil {
+		if ct != nil && ct != nct {
+			return
+		}
+		ct = nct
+	}
+
 	if ct == nil {
 		for _, t := range geneos.RealComponents() {
 			c = append(c, MatchAll(t, name)...)
@@ -301,10 +429,15 @@ func MatchAll(ct *geneos.Component, name string) (c []geneos.Instance) {
 		return
 	}
 
+	matches, err := NameMatcher(local)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
 	for _, name := range AllNames(r, ct) {
-		// for case insensitive match change to EqualFold here
 		_, ldir, _ := SplitName(name, host.ALL)
-		if filepath.Base(ldir) == local {
+		if matches(filepath.Base(ldir)) {
 			i, err := Get(ct, name)
 			if err != nil {
 				log.Println(err)
@@ -345,24 +478,27 @@ func MatchKeyValue(h *host.Host, ct *geneos.Component, key, value string) (confs
 	return
 }
 
-// get all used ports in config files on a specific remote
-// this will not work for ports assigned in component config
-// files, such as gateway setup or netprobe collection agent
+// get all used ports on a specific remote, from the instance configs,
+// gateway setup files and the ports listening on the host. ports used
+// in other component config files, such as netprobe collection agent
+// settings, are not found.
 //
-// returns a map
+// returns a map, with a nil component for ports listening that are not
+// configured for any instance
 func GetPorts(r *host.Host) (ports map[uint16]*geneos.Component) {
 	if r == host.ALL {
 		logError.Fatalln("getports() call with all hosts")
 	}
 	ports = make(map[uint16]*geneos.Component)
-	for _, c := range GetAll(r, nil) {
-		if !c.Loaded() {
-			log.Println("cannot load configuration for", c)
-			continue
-		}
-		if port := c.V().GetInt("port"); port != 0 {
-			ports[uint16(port)] = c.Type()
-		}
+	listening, err := ListeningPorts(r)
+	if err != nil {
+		logDebug.Println("cannot read listening ports on", r, err)
+	}
+	for _, port := range listening {
+		ports[port] = nil
+	}
+	for _, u := range ConfiguredPorts(r) {
+		ports[u.Port] = u.Instance.Type()
 	}
 	return
 }
@@ -388,7 +524,8 @@ func GetPorts(r *host.Host) (ports map[uint16]*geneos.Component) {
 //
 // some limits based on https://en.wikipedia.org/wiki/List_of_TCP_and_UDP_port_numbers
 //
-// not concurrency safe at this time
+// not concurrency safe, callers should hold LockPorts until the port
+// is saved in the instance configuration
 //
 func NextPort(r *host.Host, ct *geneos.Component) uint16 {
 	from := viper.GetString(ct.PortRange)
@@ -476,37 +613,6 @@ func Version(c geneos.Instance) (base string, underlying string, err error) {
 	return
 }
 
-// given a component type and a slice of args, call the function for each arg
-//
-// try to use go routines here - mutexes required
-func ForAll(ct *geneos.Component, fn func(geneos.Instance, []string) error, args []string, params []string) (err error) {
-	n := 0
-	logDebug.Println("args, params", args, params)
-	// if args is empty, get all matching instances this allows internal
-	// calls with an empty arg list without having to do the parsargs()
-	// dance
-	if len(args) == 0 {
-		args = AllNames(host.ALL, ct)
-	}
-	for _, name := range args {
-		cs := MatchAll(ct, name)
-		if len(cs) == 0 {
-			log.Println("no match for", name)
-			continue
-		}
-		n++
-		for _, c := range cs {
-			if err = fn(c, params); err != nil && !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, geneos.ErrNotSupported) {
-				log.Println(c, err)
-			}
-		}
-	}
-	if n == 0 {
-		return os.ErrNotExist
-	}
-	return nil
-}
-
 // Return a slice of all instance names for a given component. No
 // checking is done to validate that the directory is a populated
 // instance.
@@ -558,8 +664,10 @@ func SplitName(in string, defaultHost *host.Host) (ct *geneos.Component, name st
 	if len(parts) > 1 {
 		h = host.Get(parts[1])
 	}
+	// a regular expression may contain ':' so only a TYPE: before the
+	// '~' is recognised
 	parts = strings.SplitN(name, ":", 2)
-	if len(parts) > 1 {
+	if len(parts) > 1 && !strings.HasPrefix(name, "~") {
 		ct = geneos.ParseComponentName(parts[0])
 		name = parts[1]
 	}
@@ -0,0 +1,191 @@
+package instance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+)
+
+// Labels returns the free-form labels of c, from the "labels" setting.
+// Label names are not case sensitive and are returned in lower case.
+func Labels(c geneos.Instance) map[string]string {
+	return c.V().GetStringMapString("labels")
+}
+
+// LabelsString returns the labels of c as a sorted, comma separated
+// list of NAME=VALUE
+func LabelsString(c geneos.Instance) string {
+	labels := Labels(c)
+	var s []string
+	for k, v := range labels {
+		s = append(s, k+"="+v)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+// selector operators
+const (
+	selectEquals    = "="
+	selectNotEquals = "!="
+	selectExists    = "exists"
+	selectNotExists = "!exists"
+)
+
+type requirement struct {
+	key   string
+	op    string
+	value string
+}
+
+// Selector is a list of label requirements, all of which must be met
+// for an instance to match
+type Selector []requirement
+
+// ParseSelector parses a comma separated list of label requirements.
+// Each requirement is one of NAME=VALUE (or NAME==VALUE), NAME!=VALUE,
+// NAME, which requires the label to be set, or !NAME, which requires it
+// not to be set. An instance without a label NAME matches NAME!=VALUE.
+func ParseSelector(s string) (sel Selector, err error) {
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		var req requirement
+		switch {
+		case strings.Contains(r, "!="):
+			p := strings.SplitN(r, "!=", 2)
+			req = requirement{p[0], selectNotEquals, p[1]}
+		case strings.Contains(r, "=="):
+			p := strings.SplitN(r, "==", 2)
+			req = requirement{p[0], selectEquals, p[1]}
+		case strings.Contains(r, "="):
+			p := strings.SplitN(r, "=", 2)
+			req = requirement{p[0], selectEquals, p[1]}
+		case strings.HasPrefix(r, "!"):
+			req = requirement{r[1:], selectNotExists, ""}
+		default:
+			req = requirement{r, selectExists, ""}
+		}
+		req.key = strings.ToLower(strings.TrimSpace(req.key))
+		req.value = strings.TrimSpace(req.value)
+		if req.key == "" || strings.ContainsAny(req.key, "=! ") {
+			return nil, fmt.Errorf("invalid label selector %q: %w", r, geneos.ErrInvalidArgs)
+		}
+		sel = append(sel, req)
+	}
+	return
+}
+
+// Matches returns true if the labels of c meet all the requirements of
+// the selector. An empty selector matches all instances.
+func (sel Selector) Matches(c geneos.Instance) bool {
+	labels := Labels(c)
+	for _, r := range sel {
+		v, ok := labels[r.key]
+		switch r.op {
+		case selectEquals:
+			if !ok || v != r.value {
+				return false
+			}
+		case selectNotEquals:
+			if ok && v == r.value {
+				return false
+			}
+		case selectExists:
+			if !ok {
+				return false
+			}
+		case selectNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Group returns the members of the named group from the "groups"
+// setting in the global or user configuration. Each member is either an
+// instance name in the form [TYPE:]NAME[@HOST] or a label selector.
+func Group(name string) (members []string, err error) {
+	key := "groups." + name
+	if !viper.IsSet(key) {
+		return nil, fmt.Errorf("group %q not found: %w", name, geneos.ErrInvalidArgs)
+	}
+	return viper.GetStringSlice(key), nil
+}
+
+// InGroup returns true if c matches any of the group members
+func InGroup(c geneos.Instance, members []string) bool {
+	for _, m := range members {
+		if strings.ContainsAny(m, "=!") || !ValidInstanceName(m) {
+			sel, err := ParseSelector(m)
+			if err != nil {
+				logDebug.Println(err)
+				continue
+			}
+			if sel.Matches(c) {
+				return true
+			}
+			continue
+		}
+		ct, name, h := SplitName(m, host.ALL)
+		if ct != nil && ct != c.Type() {
+			continue
+		}
+		if name != c.Name() {
+			continue
+		}
+		if h != host.ALL && h != c.Host() {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Select returns the instances of type ct matching args that also match
+// sel and, if groups is not empty, are members of at least one of the
+// named groups. An empty args matches all instances.
+func Select(ct *geneos.Component, args []string, sel Selector, groups []string) (cs []geneos.Instance, err error) {
+	var members [][]string
+	for _, g := range groups {
+		m, err := Group(g)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	matched, err := match(ct, args, nil)
+	if err != nil {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, c := range matched {
+		if seen[c.String()] || !sel.Matches(c) {
+			continue
+		}
+		if len(members) > 0 {
+			var in bool
+			for _, m := range members {
+				if InGroup(c, m) {
+					in = true
+					break
+				}
+			}
+			if !in {
+				continue
+			}
+		}
+		seen[c.String()] = true
+		cs = append(cs, c)
+	}
+	return
+}
@@ -0,0 +1,129 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+)
+
+// PID file states returned by VerifyPID
+const (
+	PIDFileOK       = "ok"       // the PID file matches the running process
+	PIDFileMissing  = "missing"  // running but there is no PID file
+	PIDFileStale    = "stale"    // there is a PID file but the instance is not running
+	PIDFileMismatch = "mismatch" // the PID file does not match the running process
+	PIDFileNone     = "none"     // not running and no PID file
+)
+
+// the PID file holds the PID and the process start time, in clock ticks
+// since boot from /proc/N/stat, so that a reused PID is not mistaken
+// for the instance
+func pidFilePath(c geneos.Instance) string {
+	return ConfigPathWithExt(c, geneos.PIDExtension)
+}
+
+// procStartTime returns the start time of process pid on h in clock
+// ticks since boot
+func procStartTime(h *host.Host, pid int) (starttime int64, err error) {
+	fields, err := procStat(h, pid)
+	if err != nil {
+		return
+	}
+	_, err = fmt.Sscan(fields[19], &starttime)
+	return
+}
+
+// writePIDFile records pid and its start time for c. Errors are only
+// logged as the PID file is an optimisation and GetPID falls back to
+// looking for the process.
+func writePIDFile(c geneos.Instance, pid int) {
+	starttime, err := procStartTime(c.Host(), pid)
+	if err != nil {
+		logDebug.Println(c, "cannot read process start time:", err)
+		return
+	}
+	if err = c.Host().WriteFile(pidFilePath(c), []byte(fmt.Sprintf("%d %d\n", pid, starttime)), 0664); err != nil {
+		logDebug.Println(c, "cannot write PID file:", err)
+	}
+}
+
+func removePIDFile(c geneos.Instance) {
+	if err := c.Host().Remove(pidFilePath(c)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logDebug.Println(c, "cannot remove PID file:", err)
+	}
+}
+
+// readPIDFile returns the PID and start time recorded for c. The error
+// wraps fs.ErrNotExist if there is no PID file.
+func readPIDFile(c geneos.Instance) (pid int, starttime int64, err error) {
+	b, err := c.Host().ReadFile(pidFilePath(c))
+	if err != nil {
+		return
+	}
+	if n, _ := fmt.Sscan(string(b), &pid, &starttime); n != 2 || pid <= 0 {
+		err = fmt.Errorf("%s: invalid PID file %q", c, strings.TrimSpace(string(b)))
+	}
+	return
+}
+
+// pidFromFile returns the PID from the PID file of c if that process is
+// still running with the recorded start time and is the process of c
+func pidFromFile(c geneos.Instance) (pid int, err error) {
+	pid, starttime, err := readPIDFile(c)
+	if err != nil {
+		return
+	}
+	if !verifyPID(c, pid, starttime) {
+		return 0, os.ErrProcessDone
+	}
+	return
+}
+
+// verifyPID returns true if process pid was started at starttime and
+// has the command line of c
+func verifyPID(c geneos.Instance, pid int, starttime int64) bool {
+	if st, err := procStartTime(c.Host(), pid); err != nil || st != starttime {
+		return false
+	}
+	cmdline, err := c.Host().ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return false
+	}
+	return matchCmdline(c, cmdline)
+}
+
+// VerifyPID checks the PID file of c against the running process, if
+// any, and returns one of the PIDFile* states, the PID in the PID file
+// and the PID of the running process, either of which may be zero
+func VerifyPID(c geneos.Instance) (state string, filepid int, pid int, err error) {
+	filepid, starttime, err := readPIDFile(c)
+	missing := errors.Is(err, fs.ErrNotExist)
+	if err != nil && !missing {
+		return
+	}
+	err = nil
+	if !missing && verifyPID(c, filepid, starttime) {
+		return PIDFileOK, filepid, filepid, nil
+	}
+	pid, err = scanPID(c)
+	running := err == nil
+	if err == os.ErrProcessDone {
+		err = nil
+	}
+	switch {
+	case missing && running:
+		state = PIDFileMissing
+	case missing:
+		state = PIDFileNone
+	case running:
+		state = PIDFileMismatch
+	default:
+		state = PIDFileStale
+	}
+	return
+}
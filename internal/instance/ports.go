@@ -0,0 +1,159 @@
+package instance
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+)
+
+// the lock file, in the Geneos directory of each host, held while
+// allocating ports
+const portsLockFile = ".ports.lock"
+
+// LockPorts takes the port allocation lock for host h. The lock should
+// be held from calling NextPort until the port is written to the
+// instance configuration so that concurrent commands do not allocate
+// the same port.
+func LockPorts(h *host.Host) (unlock func(), err error) {
+	return h.Lock(h.GeneosJoinPath(portsLockFile), 30*time.Second)
+}
+
+// PortUse is a TCP port used on a host, either configured for an
+// instance or found listening
+type PortUse struct {
+	Port     uint16
+	Instance geneos.Instance // nil for a listening port of another process
+	Source   string          // "config", "setup" or "listening"
+	PID      int             // the owning process for listening ports, if known
+
+	// Uninspected is set for the configured ports of a running instance
+	// whose sockets could not be read, for example because it runs as
+	// another user, so listening ports without an owner may be its own
+	Uninspected bool
+}
+
+// ConfiguredPorts returns the ports configured for the instances on h,
+// both the "port" setting and, for gateways, the listen ports in the
+// setup file
+func ConfiguredPorts(h *host.Host) (uses []PortUse) {
+	for _, c := range GetAll(h, nil) {
+		if !c.Loaded() {
+			log.Println("cannot load configuration for", c)
+			continue
+		}
+		if port := c.V().GetInt("port"); port != 0 {
+			uses = append(uses, PortUse{Port: uint16(port), Instance: c, Source: "config"})
+		}
+		for _, port := range setupPorts(c) {
+			uses = append(uses, PortUse{Port: port, Instance: c, Source: "setup"})
+		}
+	}
+	return
+}
+
+// setupPorts returns the listen ports in a gateway setup file. Other
+// component types return nil.
+func setupPorts(c geneos.Instance) (ports []uint16) {
+	if c.Type().String() != "gateway" {
+		return
+	}
+	f, err := c.Host().Open(filepath.Join(c.Home(), "gateway.setup.xml"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	// collect any numbers inside listenPorts, whatever the nesting
+	d := xml.NewDecoder(f)
+	depth := 0
+	for {
+		t, err := d.Token()
+		if err != nil {
+			break
+		}
+		switch e := t.(type) {
+		case xml.StartElement:
+			if depth > 0 || e.Name.Local == "listenPorts" {
+				depth++
+			}
+		case xml.EndElement:
+			if depth > 0 {
+				depth--
+			}
+		case xml.CharData:
+			if depth > 0 {
+				if port, err := strconv.ParseUint(strings.TrimSpace(string(e)), 10, 16); err == nil {
+					ports = append(ports, uint16(port))
+				}
+			}
+		}
+	}
+	return
+}
+
+// PortUses returns all the ports configured for instances on h and all
+// the ports listening on h, with the listening ports matched to any
+// running instance that owns them
+func PortUses(h *host.Host) (uses []PortUse, err error) {
+	uses = ConfiguredPorts(h)
+
+	listening, err := ListeningPorts(h)
+	if err != nil {
+		return
+	}
+
+	type owner struct {
+		c   geneos.Instance
+		pid int
+	}
+	owners := make(map[int]owner)
+	uninspected := make(map[string]bool)
+	var mutex sync.Mutex
+	cs := GetAll(h, nil)
+	Run(cs, func(_ int, c geneos.Instance) error {
+		pid, err := GetPID(c)
+		if err != nil {
+			return nil
+		}
+		inodes, err := socketInodes(h, pid)
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err != nil {
+			logDebug.Printf("%s cannot read sockets of PID %d: %s", c, pid, err)
+			uninspected[c.String()] = true
+			return nil
+		}
+		for inode := range inodes {
+			owners[inode] = owner{c, pid}
+		}
+		return nil
+	}, 0)
+	for i, u := range uses {
+		if uninspected[u.Instance.String()] {
+			uses[i].Uninspected = true
+		}
+	}
+
+	seen := make(map[owner]map[uint16]bool)
+	for inode, port := range listening {
+		o := owners[inode]
+		// the same port may be listening on IPv4 and IPv6
+		if seen[o] == nil {
+			seen[o] = make(map[uint16]bool)
+		}
+		if seen[o][port] {
+			continue
+		}
+		seen[o][port] = true
+		uses = append(uses, PortUse{Port: port, Instance: o.c, Source: "listening", PID: o.pid})
+	}
+	sort.SliceStable(uses, func(i, j int) bool { return uses[i].Port < uses[j].Port })
+	return
+}
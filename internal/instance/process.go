@@ -1,54 +1,35 @@
 package instance
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
 )
 
+// Ports returns the TCP ports, IPv4 or IPv6, that instance c is
+// listening on, sorted. Use Sockets for UDP and connected sockets.
 func Ports(c geneos.Instance) (ports []int) {
-	links := Files(c)
-
-	tcp, err := c.Host().Open("/proc/net/tcp")
+	sockets, err := Sockets(c)
 	if err != nil {
-		log.Fatalln(err)
+		logDebug.Println(c, err)
+		return
 	}
-
-	// udp, _ := c.Host().ReadFile("/proc/net/udp")
-
-	tcpports := make(map[int]int)
-	scanner := bufio.NewScanner(tcp)
-	if scanner.Scan() {
-		// skip headers
-		_ = scanner.Text()
-		for scanner.Scan() {
-			line := scanner.Text()
-			fields := strings.Fields(line)
-			if len(fields) < 10 || fields[3] != "0A" {
-				break
-			}
-			var ip [4]int
-			var port int
-			fmt.Sscanf(fields[1], "%2X%2X%2X%2X:%X", &ip[0], &ip[1], &ip[2], &ip[3], &port)
-			inode, _ := strconv.Atoi(fields[9])
-			logDebug.Printf("ip %v port %v inode %v", ip, port, inode)
-			tcpports[inode] = port
-		}
-	}
-
-	var inode int
-	for _, l := range links {
-		if n, err := fmt.Sscanf(l, "socket:[%d]", &inode); err == nil && n == 1 {
-			if port, ok := tcpports[inode]; ok {
-				ports = append(ports, port)
-				logDebug.Printf("process listening on %v", port)
-			}
+	seen := make(map[uint16]bool)
+	for _, s := range sockets {
+		if s.State == "LISTEN" && !seen[s.LocalPort] {
+			seen[s.LocalPort] = true
+			ports = append(ports, int(s.LocalPort))
+			logDebug.Printf("process listening on %v", s.LocalPort)
 		}
 	}
+	sort.Ints(ports)
 	return
 }
 
@@ -75,3 +56,109 @@ func Files(c geneos.Instance) (links map[int]string) {
 	}
 	return
 }
+
+// the kernel reports process times in clock ticks of USER_HZ, which is
+// 100 on all the platforms Geneos runs on
+const clockTicks = 100
+
+// ProcessStats are the resource usage details of a running process
+type ProcessStats struct {
+	Start     time.Time     // real process start time
+	Uptime    time.Duration // time since Start, as seen by the host
+	CPUTime   time.Duration // user plus system CPU time
+	CPU       float64       // CPU time as a percentage of Uptime, like ps
+	RSS       int64         // resident set size in KiB
+	VSZ       int64         // virtual memory size in KiB
+	Threads   int
+	OpenFiles int
+}
+
+// GetProcessStats returns the PID and resource usage of the process of
+// instance c, from /proc/N/stat, /proc/N/status and /proc/N/fd and the
+// host wide /proc/stat and /proc/uptime, all of which are read through
+// c.Host() so that this works for remote instances too. The start time
+// and uptime are calculated using the host's boot time and uptime so
+// are not affected by clock differences between hosts.
+func GetProcessStats(c geneos.Instance) (pid int, stats ProcessStats, err error) {
+	pid, err = GetPID(c)
+	if err != nil {
+		return
+	}
+	h := c.Host()
+
+	fields, err := procStat(h, pid)
+	if err != nil {
+		return
+	}
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	starttime, _ := strconv.ParseInt(fields[19], 10, 64)
+	stats.CPUTime = time.Duration(utime+stime) * time.Second / clockTicks
+
+	b, err := h.ReadFile("/proc/uptime")
+	if err != nil {
+		return
+	}
+	var uptime float64
+	if _, err = fmt.Sscan(string(b), &uptime); err != nil {
+		return
+	}
+	stats.Uptime = time.Duration(uptime*float64(time.Second)) - time.Duration(starttime)*time.Second/clockTicks
+	if stats.Uptime > 0 {
+		stats.CPU = 100 * stats.CPUTime.Seconds() / stats.Uptime.Seconds()
+	}
+
+	if b, err = h.ReadFile("/proc/stat"); err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "btime ") {
+			btime, _ := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "btime ")), 10, 64)
+			stats.Start = time.Unix(btime, 0).Add(time.Duration(starttime) * time.Second / clockTicks)
+			break
+		}
+	}
+
+	if b, err = h.ReadFile(fmt.Sprintf("/proc/%d/status", pid)); err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue
+		}
+		switch f[0] {
+		case "VmRSS:":
+			stats.RSS, _ = strconv.ParseInt(f[1], 10, 64)
+		case "VmSize:":
+			stats.VSZ, _ = strconv.ParseInt(f[1], 10, 64)
+		case "Threads:":
+			stats.Threads, _ = strconv.Atoi(f[1])
+		}
+	}
+
+	// the fd directory is only readable by the process owner, so
+	// leave OpenFiles as zero rather than fail
+	if fds, err := h.ReadDir(fmt.Sprintf("/proc/%d/fd", pid)); err == nil {
+		stats.OpenFiles = len(fds)
+	}
+	return
+}
+
+// procStat returns the fields of /proc/N/stat for process pid on h after
+// the command name, so that fields[0] is the state, field 3 in proc(5)
+func procStat(h *host.Host, pid int) (fields []string, err error) {
+	b, err := h.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return
+	}
+	// the command name is in parentheses and may contain spaces
+	i := bytes.LastIndexByte(b, ')')
+	if i != -1 {
+		fields = strings.Fields(string(b[i+1:]))
+	}
+	if len(fields) < 22 {
+		return nil, fmt.Errorf("cannot parse /proc/%d/stat", pid)
+	}
+	return
+}
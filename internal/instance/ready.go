@@ -0,0 +1,108 @@
+package instance
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+)
+
+// the number of lines from the end of the .txt file included in the
+// error when an instance does not become ready
+const readyOutputLines = 20
+
+// StartWait is Start but, if timeout is not zero and c is started, also
+// waits for c to be ready, see WaitReady. If c does not become ready the
+// result is Failed and the error includes the end of the instance's
+// startup output.
+func StartWait(c geneos.Instance, timeout time.Duration) (result Result) {
+	result = Start(c)
+	if result.Outcome != OK || timeout == 0 || host.DryRun {
+		return
+	}
+	if err := WaitReady(c, timeout); err != nil {
+		return result.Fail(err)
+	}
+	result.Message += ", ready"
+	return
+}
+
+// WaitReady waits up to timeout for c to be ready, which is when it is
+// listening on the port in its configuration. The port is checked in
+// the socket tables of its host, which are only readable for processes
+// of the same user, and otherwise by connecting to it from this host.
+// Instances without a port are ready once running. An error is
+// returned, with the end of the instance's startup output, if the
+// process exits or is not listening by the timeout. In dry run mode
+// nothing has been started so WaitReady returns at once.
+func WaitReady(c geneos.Instance, timeout time.Duration) (err error) {
+	if host.DryRun {
+		return nil
+	}
+	port := c.V().GetInt("port")
+	for deadline := time.Now().Add(timeout); ; {
+		if _, err = GetPID(c); err != nil {
+			return startupError(c, fmt.Errorf("exited during startup"))
+		}
+		if port == 0 || listening(c, port) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return startupError(c, fmt.Errorf("not listening on port %d after %v", port, timeout))
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// listening returns true if c is listening on port. If the sockets of
+// c cannot be read then try to connect to the port instead.
+func listening(c geneos.Instance, port int) bool {
+	sockets, err := Sockets(c)
+	if err == nil {
+		for _, s := range sockets {
+			if s.State == "LISTEN" && int(s.LocalPort) == port {
+				return true
+			}
+		}
+		return false
+	}
+	logDebug.Println(c, err)
+
+	hostname := "localhost"
+	if c.Host() != host.LOCAL {
+		hostname = c.Host().GetString("hostname")
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(hostname, strconv.Itoa(port)), time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// startupError returns err with the last lines of the .txt file that
+// holds the startup output of c appended
+func startupError(c geneos.Instance, err error) error {
+	b, rerr := c.Host().ReadFile(ConfigPathWithExt(c, "txt"))
+	if rerr != nil {
+		if !os.IsNotExist(rerr) {
+			logDebug.Println(c, rerr)
+		}
+		return err
+	}
+	lines := bytes.Split(bytes.TrimRight(b, "\n"), []byte("\n"))
+	if len(lines) > readyOutputLines {
+		lines = lines[len(lines)-readyOutputLines:]
+	}
+	output := strings.TrimSpace(string(bytes.Join(lines, []byte("\n"))))
+	if output == "" {
+		return err
+	}
+	return fmt.Errorf("%w, output:\n%s", err, output)
+}
@@ -0,0 +1,89 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"wonderland.org/geneos/internal/geneos"
+)
+
+// Outcome classifies the Result of an action on an instance
+type Outcome string
+
+const (
+	OK        Outcome = "ok"        // the action was carried out
+	Unchanged Outcome = "unchanged" // nothing to do, e.g. already running
+	Skipped   Outcome = "skipped"   // the action does not apply to the instance
+	Failed    Outcome = "failed"    // the action failed, see Err
+)
+
+// Result is the outcome of an action on a single instance. Action
+// functions return a Result instead of logging so that the caller can
+// render it as text, JSON or CSV.
+type Result struct {
+	Instance geneos.Instance
+	Action   string
+	Outcome  Outcome
+	PID      int
+	Message  string
+	Err      error
+}
+
+// NewResult returns a Result for action on c with an OK outcome
+func NewResult(c geneos.Instance, action string) Result {
+	return Result{Instance: c, Action: action, Outcome: OK}
+}
+
+// Set returns r with the outcome and message updated
+func (r Result) Set(outcome Outcome, format string, args ...interface{}) Result {
+	r.Outcome = outcome
+	r.Message = fmt.Sprintf(format, args...)
+	return r
+}
+
+// Fail returns r with the error err. A nil err leaves r unchanged.
+// os.ErrProcessDone and geneos.ErrNotSupported are treated as Skipped
+// rather than Failed, as ForAll has always ignored them.
+func (r Result) Fail(err error) Result {
+	if err == nil {
+		return r
+	}
+	r.Err = err
+	r.Outcome = Failed
+	if errors.Is(err, os.ErrProcessDone) || errors.Is(err, geneos.ErrNotSupported) {
+		r.Outcome = Skipped
+	}
+	return r
+}
+
+// String returns the instance followed by the message, or the error if
+// there is no message, in the same form as the log lines the actions
+// used to write
+func (r Result) String() string {
+	switch {
+	case r.Message != "":
+		return r.Instance.String() + " " + r.Message
+	case r.Err != nil:
+		return r.Instance.String() + " " + r.Err.Error()
+	default:
+		return r.Instance.String()
+	}
+}
+
+// Results is an ordered list of Result
+type Results []Result
+
+// Err returns the failed results as Errors or nil if none failed
+func (rs Results) Err() error {
+	var errs Errors
+	for _, r := range rs {
+		if r.Outcome == Failed {
+			errs = append(errs, InstanceError{r.Instance, r.Err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package instance
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+)
+
+// Socket is an entry from the kernel socket tables in /proc/net
+type Socket struct {
+	Proto      string // "tcp", "tcp6", "udp" or "udp6"
+	LocalAddr  net.IP
+	LocalPort  uint16
+	RemoteAddr net.IP
+	RemotePort uint16
+	State      string // e.g. "LISTEN" or "ESTABLISHED", "UNCONN" for unconnected UDP
+	Inode      int
+}
+
+// Local returns the local address and port of s
+func (s Socket) Local() string {
+	return net.JoinHostPort(s.LocalAddr.String(), strconv.Itoa(int(s.LocalPort)))
+}
+
+// Remote returns the remote address and port of s
+func (s Socket) Remote() string {
+	return net.JoinHostPort(s.RemoteAddr.String(), strconv.Itoa(int(s.RemotePort)))
+}
+
+// the states in include/net/tcp_states.h
+var socketStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// HostSockets returns all the TCP and UDP sockets, IPv4 and IPv6, on
+// host h. Missing tables, e.g. when IPv6 is disabled, are skipped but
+// an error is returned if none can be read.
+func HostSockets(h *host.Host) (sockets []Socket, err error) {
+	var found bool
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		s, err := readSockets(h, proto)
+		if err != nil {
+			logDebug.Println(h, err)
+			continue
+		}
+		found = true
+		sockets = append(sockets, s...)
+	}
+	if !found {
+		err = fmt.Errorf("%s: cannot read socket tables in /proc/net", h)
+	}
+	return
+}
+
+func readSockets(h *host.Host, proto string) (sockets []Socket, err error) {
+	f, err := h.Open("/proc/net/" + proto)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// skip headers
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		s := Socket{Proto: proto, State: socketStates[fields[3]]}
+		if s.LocalAddr, s.LocalPort, err = parseSocketAddr(fields[1]); err != nil {
+			continue
+		}
+		if s.RemoteAddr, s.RemotePort, err = parseSocketAddr(fields[2]); err != nil {
+			continue
+		}
+		if strings.HasPrefix(proto, "udp") && s.State == "CLOSE" {
+			s.State = "UNCONN"
+		}
+		s.Inode, _ = strconv.Atoi(fields[9])
+		sockets = append(sockets, s)
+	}
+	return sockets, scanner.Err()
+}
+
+// parseSocketAddr parses an address in the form used in /proc/net,
+// which is the hex address as 32 bit words in host byte order (assumed
+// little endian) followed by ":" and the hex port
+func parseSocketAddr(in string) (ip net.IP, port uint16, err error) {
+	s := strings.SplitN(in, ":", 2)
+	if len(s) != 2 {
+		return nil, 0, fmt.Errorf("invalid address %q", in)
+	}
+	b, err := hex.DecodeString(s[0])
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid address %q", in)
+	}
+	for i := 0; i < len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	p, err := strconv.ParseUint(s[1], 16, 16)
+	if err != nil {
+		return
+	}
+	return net.IP(b), uint16(p), nil
+}
+
+// ListeningPorts returns the listening TCP sockets, IPv4 and IPv6, on
+// host h as a map of socket inode to port
+func ListeningPorts(h *host.Host) (ports map[int]uint16, err error) {
+	sockets, err := HostSockets(h)
+	if err != nil {
+		return
+	}
+	ports = make(map[int]uint16)
+	for _, s := range sockets {
+		if s.State == "LISTEN" {
+			ports[s.Inode] = s.LocalPort
+		}
+	}
+	return
+}
+
+// Sockets returns the sockets open by the process of instance c. An
+// error is returned if c is not running, os.ErrProcessDone, or the
+// sockets cannot be read, for example if the process belongs to another
+// user.
+func Sockets(c geneos.Instance) (sockets []Socket, err error) {
+	pid, err := GetPID(c)
+	if err != nil {
+		return
+	}
+	inodes, err := socketInodes(c.Host(), pid)
+	if err != nil {
+		return
+	}
+	all, err := HostSockets(c.Host())
+	if err != nil {
+		return
+	}
+	for _, s := range all {
+		if inodes[s.Inode] {
+			sockets = append(sockets, s)
+		}
+	}
+	return
+}
+
+// socketInodes returns the inodes of the sockets open by process pid
+// on host h
+func socketInodes(h *host.Host, pid int) (inodes map[int]bool, err error) {
+	path := fmt.Sprintf("/proc/%d/fd", pid)
+	fds, err := h.ReadDir(path)
+	if err != nil {
+		return
+	}
+	inodes = make(map[int]bool)
+	var readable bool
+	for _, ent := range fds {
+		dest, lerr := h.Readlink(filepath.Join(path, ent.Name()))
+		if lerr != nil {
+			err = lerr
+			continue
+		}
+		readable = true
+		var inode int
+		if n, err := fmt.Sscanf(dest, "socket:[%d]", &inode); err == nil && n == 1 {
+			inodes[inode] = true
+		}
+	}
+	// only report an error if none of the descriptors could be read
+	if readable || len(fds) == 0 {
+		err = nil
+	}
+	return
+}
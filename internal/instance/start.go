@@ -1,8 +1,10 @@
 package instance
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
@@ -11,29 +13,88 @@ import (
 	"wonderland.org/geneos/internal/utils"
 )
 
-func Start(c geneos.Instance) (err error) {
+// Start the instance c unless it is already running or disabled. Any
+// stopped mark left by Stop is removed. Instances managed by systemd are
+// started with systemctl.
+//
+// The "prestart" hook is run first and, if it fails, c is not started.
+// The "poststart" hook is run after c has started, with the PID in
+// GENEOS_PID, and a failure is logged.
+func Start(c geneos.Instance) (result Result) {
+	result = NewResult(c, "start")
 	pid, err := GetPID(c)
 	if err == nil {
-		log.Println(c, "already running with PID", pid)
-		return
+		clearStopped(c)
+		result.PID = pid
+		return result.Set(Unchanged, "already running with PID %d", pid)
 	}
 
 	if IsDisabled(c) {
-		return geneos.ErrDisabled
+		result.Err = geneos.ErrDisabled
+		return result.Set(Skipped, "disabled")
+	}
+
+	if err = RunHook(c, geneos.HookPreStart); err != nil {
+		return result.Fail(err)
+	}
+
+	if host.DryRun {
+		return startDryRun(c)
 	}
 
+	if IsSystemd(c) {
+		pid, err = startSystemd(c)
+	} else {
+		pid, err = start(c)
+	}
+	if err != nil {
+		return result.Fail(err)
+	}
+	clearStopped(c)
+	if err = RunHook(c, geneos.HookPostStart, fmt.Sprintf("GENEOS_PID=%d", pid)); err != nil {
+		log.Println(c, err)
+	}
+	result.PID = pid
+	return result.Set(OK, "started with PID %d", pid)
+}
+
+// startDryRun reports how c would be started
+func startDryRun(c geneos.Instance) (result Result) {
+	result = NewResult(c, "start")
+	if IsSystemd(c) {
+		systemctl(c, "start", SystemdUnitName(c))
+	} else {
+		cmd, env := BuildCmd(c)
+		if cmd == nil {
+			return result.Fail(fmt.Errorf("buildCommand returned nil"))
+		}
+		c.Host().DryRunf("start %s in %s with environment %s", strings.Join(cmd.Args, " "), c.Home(), strings.Join(env, " "))
+	}
+	clearStopped(c)
+	if err := RunHook(c, geneos.HookPostStart); err != nil {
+		log.Println(c, err)
+	}
+	return result.Set(OK, "would start")
+}
+
+// start runs the process for c and records its PID file
+func start(c geneos.Instance) (pid int, err error) {
+	if pid, err = startProcess(c); err == nil {
+		writePIDFile(c, pid)
+	}
+	return
+}
+
+func startProcess(c geneos.Instance) (pid int, err error) {
+
 	binary := c.V().GetString("program")
 	if _, err = c.Host().Stat(binary); err != nil {
-		return fmt.Errorf("%q %w", binary, err)
+		return 0, fmt.Errorf("%q %w", binary, err)
 	}
 
 	cmd, env := BuildCmd(c)
 	if cmd == nil {
-		return fmt.Errorf("buildCommand returned nil")
-	}
-
-	if !utils.CanControl(c.V().GetString("user")) {
-		return os.ErrPermission
+		return 0, fmt.Errorf("buildCommand returned nil")
 	}
 
 	// set underlying user for child proc
@@ -42,18 +103,19 @@ func Start(c geneos.Instance) (err error) {
 
 	if c.Host() != host.LOCAL {
 		r := c.Host()
-		rUsername := r.GetString("username")
-		if rUsername != username && username != "" {
-			return fmt.Errorf("cannot run remote process as a different user (%q != %q)", rUsername, username)
+		sudouser, err := sudoUser(c)
+		if err != nil {
+			return 0, err
 		}
 		rem, err := r.Dial()
 		if err != nil {
-			return err
+			return 0, err
 		}
 		sess, err := rem.NewSession()
 		if err != nil {
-			return err
+			return 0, err
 		}
+		defer sess.Close()
 
 		// we have to convert cmd to a string ourselves as we have to quote any args
 		// with spaces (like "Demo Gateway")
@@ -65,11 +127,20 @@ func Start(c geneos.Instance) (err error) {
 		}
 		pipe, err := sess.StdinPipe()
 		if err != nil {
-			return err
+			return 0, err
 		}
-
-		if err = sess.Shell(); err != nil {
-			return err
+		var stderr bytes.Buffer
+		sess.Stderr = &stderr
+
+		// the commands are sent to a shell which, if the instance runs
+		// as another user, is run as that user through sudo
+		if sudouser != "" {
+			err = sess.Start(host.SudoCommand(sudouser, "/bin/sh"))
+		} else {
+			err = sess.Shell()
+		}
+		if err != nil {
+			return 0, err
 		}
 		fmt.Fprintln(pipe, "cd", c.Home())
 		for _, e := range env {
@@ -77,16 +148,21 @@ func Start(c geneos.Instance) (err error) {
 		}
 		fmt.Fprintf(pipe, "%s > %q 2>&1 &", cmdstr, errfile)
 		fmt.Fprintln(pipe, "exit")
-		sess.Close()
+		pipe.Close()
+		if err = sess.Wait(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return 0, fmt.Errorf("%s: %s", err, msg)
+			}
+			return 0, err
+		}
 		// wait a short while for remote to catch-up
 		time.Sleep(250 * time.Millisecond)
 
-		pid, err := GetPID(c)
-		if err != nil {
-			return err
-		}
-		log.Println(c, "started with PID", pid)
-		return nil
+		return scanPID(c)
+	}
+
+	if !utils.CanControl(username) {
+		return 0, os.ErrPermission
 	}
 
 	// pass possibly empty string down to setuser - it handles defaults
@@ -96,9 +172,9 @@ func Start(c geneos.Instance) (err error) {
 
 	cmd.Env = append(os.Environ(), env...)
 
-	out, err := os.OpenFile(errfile, os.O_CREATE|os.O_WRONLY, 0644)
+	out, err := os.OpenFile(errfile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
-		return err
+		return
 	}
 
 	// if we've set-up privs at all, set the redirection output file to the same
@@ -119,7 +195,7 @@ func Start(c geneos.Instance) (err error) {
 	if err = cmd.Start(); err != nil {
 		return
 	}
-	log.Println(c, "started with PID", cmd.Process.Pid)
+	pid = cmd.Process.Pid
 	if cmd.Process != nil {
 		// detach from control
 		cmd.Process.Release()
@@ -2,47 +2,264 @@ package instance
 
 import (
 	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/spf13/viper"
 	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
 )
 
-func Stop(c geneos.Instance, force bool) (err error) {
+// Stop the instance c using its stop policy, see StopPolicy, or with an
+// immediate SIGKILL if force is set. Instances managed by systemd are
+// stopped with systemctl. Unless the stop fails the instance is marked
+// as stopped so that a supervisor does not restart it.
+//
+// Unless force is set the "prestop" hook is run first, if c is running,
+// and the "poststop" hook after c has stopped. Hook failures are logged
+// but do not stop c being stopped.
+func Stop(c geneos.Instance, force bool) (result Result) {
+	return StopWait(c, force, 0)
+}
+
+// StopWait is Stop but waits up to timeout, if not zero, instead of the
+// policy timeout for the instance to exit before sending a SIGKILL. The
+// result message includes how long the instance took to stop. A
+// negative timeout is an error.
+func StopWait(c geneos.Instance, force bool, timeout time.Duration) (result Result) {
+	if timeout < 0 {
+		return NewResult(c, "stop").Fail(fmt.Errorf("stop timeout must not be negative: %w", geneos.ErrInvalidArgs))
+	}
+	policy, err := GetStopPolicy(c)
+	if err != nil {
+		return NewResult(c, "stop").Fail(err)
+	}
+	if timeout != 0 {
+		policy.Timeout = timeout
+	}
+
 	if !force {
-		err = Signal(c, syscall.SIGTERM)
+		if pid, err := GetPID(c); err == nil {
+			if err = RunHook(c, geneos.HookPreStop, fmt.Sprintf("GENEOS_PID=%d", pid)); err != nil {
+				log.Println(c, err)
+			}
+		}
+	}
+
+	start := time.Now()
+	if host.DryRun {
+		result = stopDryRun(c, force, policy)
+	} else if IsSystemd(c) {
+		result = stopSystemd(c, force)
+	} else {
+		result = stop(c, force, policy)
+	}
+	if result.Outcome == OK || result.Outcome == Unchanged {
+		setStopped(c)
+		removePIDFile(c)
+	}
+	if result.Outcome == OK {
+		if !host.DryRun {
+			result.Message += " in " + time.Since(start).Round(10*time.Millisecond).String()
+		}
+		if !force {
+			if err := RunHook(c, geneos.HookPostStop); err != nil {
+				log.Println(c, err)
+			}
+		}
+	}
+	return
+}
+
+// StopPolicy is how an instance is stopped. The signal is sent once and
+// the process is checked every Poll until it exits or Timeout passes,
+// after which it is sent a SIGKILL.
+type StopPolicy struct {
+	Signal  syscall.Signal
+	Timeout time.Duration
+	Poll    time.Duration
+}
+
+// the stop policy defaults for component types that do not set their
+// own TYPEStopSignal etc. in the global configuration
+var defaultStopPolicy = StopPolicy{
+	Signal:  syscall.SIGTERM,
+	Timeout: 2500 * time.Millisecond,
+	Poll:    250 * time.Millisecond,
+}
+
+// GetStopPolicy returns the stop policy for c. Each value comes from the
+// instance settings "stopsignal", "stoptimeout" and "stoppoll" or, if
+// not set, the global settings "TYPEStopSignal", "TYPEStopTimeout" and
+// "TYPEStopPoll" for the component type, e.g. "GatewayStopTimeout", or
+// the built-in defaults of a SIGTERM, 2.5 seconds and 250 milliseconds.
+func GetStopPolicy(c geneos.Instance) (policy StopPolicy, err error) {
+	policy = defaultStopPolicy
+	setting := func(key string) string {
+		if v := c.V().GetString(strings.ToLower(key)); v != "" {
+			return v
+		}
+		return viper.GetString(c.Type().String() + key)
+	}
+
+	if v := setting("StopSignal"); v != "" {
+		if policy.Signal, err = ParseSignal(v); err != nil {
+			return
+		}
+	}
+	if v := setting("StopTimeout"); v != "" {
+		if policy.Timeout, err = time.ParseDuration(v); err != nil {
+			return
+		}
+		if policy.Timeout < 0 {
+			return policy, fmt.Errorf("stop timeout must not be negative: %w", geneos.ErrInvalidArgs)
+		}
+	}
+	if v := setting("StopPoll"); v != "" {
+		if policy.Poll, err = time.ParseDuration(v); err != nil {
+			return
+		}
+		if policy.Poll <= 0 {
+			return policy, fmt.Errorf("stop poll interval must be positive: %w", geneos.ErrInvalidArgs)
+		}
+	}
+	return
+}
+
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
+
+// SignalName returns the name of sig, e.g. "SIGTERM", or the number as
+// a string if it is not one of the signals that ParseSignal knows
+func SignalName(sig syscall.Signal) string {
+	for name, s := range signalNames {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// ParseSignal returns the signal for a name, with or without the "SIG"
+// prefix and in any case, or a number
+func ParseSignal(name string) (sig syscall.Signal, err error) {
+	if n, err := strconv.Atoi(name); err == nil && n > 0 && n < 65 {
+		return syscall.Signal(n), nil
+	}
+	sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		err = fmt.Errorf("unknown signal %q: %w", name, geneos.ErrInvalidArgs)
+	}
+	return
+}
+
+func stop(c geneos.Instance, force bool, policy StopPolicy) (result Result) {
+	result = NewResult(c, "stop")
+	if !force {
+		err := Signal(c, policy.Signal)
 		if err == os.ErrProcessDone {
-			return nil
+			return result.Set(Unchanged, "")
 		}
 
 		if errors.Is(err, syscall.EPERM) {
-			return nil
+			result.Err = err
+			return result.Set(Skipped, "")
 		}
 
-		for i := 0; i < 10; i++ {
-			time.Sleep(250 * time.Millisecond)
-			err = Signal(c, syscall.SIGTERM)
-			if err == os.ErrProcessDone {
-				break
+		for deadline := time.Now().Add(policy.Timeout); time.Now().Before(deadline); {
+			time.Sleep(policy.Poll)
+			if _, err = GetPID(c); err == os.ErrProcessDone {
+				return result.Set(OK, "stopped")
 			}
 		}
-
-		if _, err = GetPID(c); err == os.ErrProcessDone {
-			log.Println(c, "stopped")
-			return nil
-		}
 	}
 
-	if err = Signal(c, syscall.SIGKILL); err == os.ErrProcessDone {
-		return nil
+	if err := Signal(c, syscall.SIGKILL); err == os.ErrProcessDone {
+		if force {
+			return result.Set(Unchanged, "")
+		}
+		// exited between the last check and the SIGKILL
+		return result.Set(OK, "stopped")
 	}
 
 	time.Sleep(250 * time.Millisecond)
-	_, err = GetPID(c)
+	_, err := GetPID(c)
 	if err == os.ErrProcessDone {
-		log.Println(c, "killed")
-		return nil
+		if force {
+			return result.Set(OK, "killed")
+		}
+		return result.Set(OK, "killed after %v timeout", policy.Timeout)
+	}
+	return result.Fail(err)
+}
+
+// IsStopped returns true if the instance was last stopped by Stop
+// rather than started by Start
+func IsStopped(c geneos.Instance) bool {
+	f, err := c.Host().Stat(ConfigPathWithExt(c, geneos.StoppedExtension))
+	return err == nil && f.St.Mode().IsRegular()
+}
+
+// in dry run mode the instances that would have been stopped, so that
+// a later start in the same command, e.g. restart, plans to start them
+var dryRunStoppedInstances sync.Map
+
+func dryRunStopped(c geneos.Instance) bool {
+	if !host.DryRun {
+		return false
+	}
+	_, ok := dryRunStoppedInstances.Load(c.String())
+	return ok
+}
+
+// stopDryRun reports how c would be stopped
+func stopDryRun(c geneos.Instance, force bool, policy StopPolicy) (result Result) {
+	result = NewResult(c, "stop")
+	pid, err := GetPID(c)
+	if err != nil {
+		return result.Set(Unchanged, "")
+	}
+	switch {
+	case IsSystemd(c):
+		unit := SystemdUnitName(c)
+		if force {
+			systemctl(c, "kill", "--signal", "SIGKILL", unit)
+		}
+		systemctl(c, "stop", unit)
+	case force:
+		c.Host().DryRunf("send SIGKILL to %s PID %d", c, pid)
+	default:
+		c.Host().DryRunf("send %s to %s PID %d, then SIGKILL if still running after %v", SignalName(policy.Signal), c, pid, policy.Timeout)
+	}
+	dryRunStoppedInstances.Store(c.String(), true)
+	result.PID = pid
+	return result.Set(OK, "would stop PID %d", pid)
+}
+
+func setStopped(c geneos.Instance) {
+	f, err := c.Host().Create(ConfigPathWithExt(c, geneos.StoppedExtension), 0664)
+	if err != nil {
+		logDebug.Println(c, "cannot mark as stopped:", err)
+		return
+	}
+	f.Close()
+}
+
+func clearStopped(c geneos.Instance) {
+	if err := c.Host().Remove(ConfigPathWithExt(c, geneos.StoppedExtension)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logDebug.Println(c, "cannot clear stopped mark:", err)
 	}
-	return
 }
@@ -0,0 +1,194 @@
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"wonderland.org/geneos/internal/geneos"
+)
+
+// the number of entries kept in each instance's restart history
+const restartHistoryLen = 100
+
+// Supervisor restarts instances that have stopped unexpectedly, that is
+// instances that have been seen running and have then stopped without
+// being stopped by Stop or disabled.
+//
+// After each restart of an instance the delay before the next one starts
+// at Backoff and doubles up to MaxBackoff. The delay is reset once the
+// instance has stayed up for Window. If an instance needs more than
+// MaxRestarts restarts in Window then the supervisor gives up on it until
+// it is seen running again, e.g. after a manual start.
+type Supervisor struct {
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	MaxRestarts int
+	Window      time.Duration
+
+	mutex  sync.Mutex
+	states map[string]*superviseState
+}
+
+// superviseState is what the supervisor remembers about an instance
+// between checks
+type superviseState struct {
+	seen     bool        // seen running since last stopped or disabled
+	down     bool        // found stopped since last seen running
+	gaveUp   bool        // too many restarts in window
+	attempts int         // restarts since last up for a full window
+	next     time.Time   // no restart before this time
+	restarts []time.Time // restarts within window
+}
+
+// Restart is an entry in an instance's restart history
+type Restart struct {
+	Time   time.Time
+	Action string // "restart" or "giveup"
+	PID    int    `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+func (s *Supervisor) state(c geneos.Instance) *superviseState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.states == nil {
+		s.states = make(map[string]*superviseState)
+	}
+	st, ok := s.states[c.String()]
+	if !ok {
+		st = &superviseState{}
+		s.states[c.String()] = st
+	}
+	return st
+}
+
+// Check looks at instance c once and restarts it if required. The
+// Result is Unchanged unless a restart was attempted or the supervisor
+// gave up on c. Check may be called concurrently for different
+// instances.
+func (s *Supervisor) Check(c geneos.Instance) (result Result) {
+	result = NewResult(c, "supervise")
+	st := s.state(c)
+	now := time.Now()
+
+	// systemd restarts the instances it manages
+	if IsDisabled(c) || IsStopped(c) || IsSystemd(c) {
+		*st = superviseState{}
+		return result.Set(Unchanged, "")
+	}
+
+	pid, err := GetPID(c)
+	if err == nil {
+		result.PID = pid
+		st.seen = true
+		st.down = false
+		st.gaveUp = false
+		if st.attempts > 0 && now.Sub(st.restarts[len(st.restarts)-1]) >= s.Window {
+			st.attempts = 0
+		}
+		return result.Set(Unchanged, "")
+	}
+	if err != os.ErrProcessDone {
+		return result.Fail(err)
+	}
+	// run the poststop hook once for an unexpected stop, as Stop would
+	// for a deliberate one. There is no process left for prestop.
+	if st.seen && !st.down {
+		st.down = true
+		if err = RunHook(c, geneos.HookPostStop); err != nil {
+			log.Println(c, err)
+		}
+	}
+	if !st.seen || st.gaveUp || now.Before(st.next) {
+		return result.Set(Unchanged, "")
+	}
+
+	var recent []time.Time
+	for _, t := range st.restarts {
+		if now.Sub(t) < s.Window {
+			recent = append(recent, t)
+		}
+	}
+	st.restarts = recent
+	if len(st.restarts) >= s.MaxRestarts {
+		st.gaveUp = true
+		err = fmt.Errorf("restarted %d times in %v, giving up", len(st.restarts), s.Window)
+		s.record(c, Restart{Time: now, Action: "giveup", Error: err.Error()})
+		return result.Fail(err)
+	}
+
+	st.attempts++
+	st.restarts = append(st.restarts, now)
+	st.next = now.Add(s.backoff(st.attempts))
+
+	// restart through Start so that the prestart and poststart hooks
+	// are run, as for any other start
+	r := Start(c)
+	pid, err = r.PID, r.Err
+	s.record(c, Restart{Time: now, Action: "restart", PID: pid, Error: errString(err)})
+	if err != nil {
+		return result.Fail(err)
+	}
+	result.PID = pid
+	return result.Set(OK, "restarted with PID %d (attempt %d)", pid, st.attempts)
+}
+
+// backoff returns the delay after the given number of restart attempts
+func (s *Supervisor) backoff(attempts int) (d time.Duration) {
+	d = s.Backoff
+	for i := 1; i < attempts && d < s.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.MaxBackoff {
+		d = s.MaxBackoff
+	}
+	return
+}
+
+// record adds r to the restart history of c, logging any error as the
+// history must not stop the supervisor
+func (s *Supervisor) record(c geneos.Instance, r Restart) {
+	history, err := RestartHistory(c)
+	if err != nil {
+		logError.Println(c, "cannot read restart history:", err)
+	}
+	history = append(history, r)
+	if len(history) > restartHistoryLen {
+		history = history[len(history)-restartHistoryLen:]
+	}
+	b, err := json.MarshalIndent(history, "", "    ")
+	if err != nil {
+		logError.Println(c, err)
+		return
+	}
+	if err = c.Host().WriteFile(ConfigPathWithExt(c, geneos.RestartsExtension), b, 0664); err != nil {
+		logError.Println(c, "cannot write restart history:", err)
+	}
+}
+
+// RestartHistory returns the restart history of c, oldest first. An
+// instance that has never been restarted by a supervisor has an empty
+// history.
+func RestartHistory(c geneos.Instance) (history []Restart, err error) {
+	b, err := c.Host().ReadFile(ConfigPathWithExt(c, geneos.RestartsExtension))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	err = json.Unmarshal(b, &history)
+	return
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,201 @@
+package instance
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"wonderland.org/geneos/internal/geneos"
+	"wonderland.org/geneos/internal/host"
+)
+
+// an instance is managed by systemd when the "systemd" setting is
+// either "system" or "user", which is the systemctl scope of its unit
+const (
+	SystemdSystem = "system"
+	SystemdUser   = "user"
+)
+
+// IsSystemd returns true if c is managed by systemd, in which case Start
+// and Stop use systemctl
+func IsSystemd(c geneos.Instance) bool {
+	scope := c.V().GetString("systemd")
+	return scope == SystemdSystem || scope == SystemdUser
+}
+
+// SystemdScope returns the default systemd scope for units on host h,
+// the system manager for root and otherwise the user's manager
+func SystemdScope(h *host.Host) string {
+	if h == host.LOCAL {
+		if os.Geteuid() == 0 {
+			return SystemdSystem
+		}
+		return SystemdUser
+	}
+	if h.GetString("username") == "root" {
+		return SystemdSystem
+	}
+	return SystemdUser
+}
+
+// SystemdUnitDir returns the directory on host h for units in scope.
+// User units on remote hosts are relative to the login directory.
+func SystemdUnitDir(h *host.Host, scope string) (dir string, err error) {
+	if scope == SystemdSystem {
+		return "/etc/systemd/system", nil
+	}
+	if h == host.LOCAL {
+		var home string
+		if home, err = os.UserHomeDir(); err != nil {
+			return
+		}
+		return home + "/.config/systemd/user", nil
+	}
+	return ".config/systemd/user", nil
+}
+
+// SystemdUnitName returns the name of the service unit for c. The
+// instance name is escaped in the same way as systemd-escape.
+func SystemdUnitName(c geneos.Instance) string {
+	var b strings.Builder
+	for i, ch := range []byte(c.Name()) {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9', ch == ':', ch == '_', ch == '.' && i > 0:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, `\x%02x`, ch)
+		}
+	}
+	return "geneos-" + c.Type().String() + "-" + b.String() + ".service"
+}
+
+// SystemdUnit returns the contents of a service unit for c in scope,
+// built from the same command line and environment as Start uses
+func SystemdUnit(c geneos.Instance, scope string) (unit []byte, err error) {
+	cmd, env := BuildCmd(c)
+	if cmd == nil {
+		return nil, fmt.Errorf("buildCommand returned nil")
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# created by geneos, changes will be overwritten by 'geneos systemd'\n")
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=Geneos %s %s\n", c.Type(), systemdEscape(c.Name()))
+	if scope == SystemdSystem {
+		fmt.Fprintf(&b, "Wants=network-online.target\n")
+		fmt.Fprintf(&b, "After=network-online.target\n")
+	}
+	fmt.Fprintf(&b, "\n[Service]\n")
+	fmt.Fprintf(&b, "Type=simple\n")
+	if username := c.V().GetString("user"); username != "" && scope == SystemdSystem {
+		fmt.Fprintf(&b, "User=%s\n", username)
+	}
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdEscape(c.Home()))
+	for _, e := range env {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(e))
+	}
+	var args []string
+	for _, a := range cmd.Args {
+		args = append(args, systemdQuote(a))
+	}
+	args[0] = systemdQuote(cmd.Path)
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(args, " "))
+	if sig := c.Type().ReloadSignal; sig != 0 {
+		fmt.Fprintf(&b, "ExecReload=/bin/kill -%d $MAINPID\n", sig)
+	}
+	fmt.Fprintf(&b, "StandardOutput=append:%s\n", systemdEscape(ConfigPathWithExt(c, "txt")))
+	fmt.Fprintf(&b, "StandardError=inherit\n")
+	if policy, err := GetStopPolicy(c); err == nil {
+		fmt.Fprintf(&b, "KillSignal=%s\n", SignalName(policy.Signal))
+		fmt.Fprintf(&b, "TimeoutStopSec=%dms\n", policy.Timeout.Milliseconds())
+	}
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "\n[Install]\n")
+	if scope == SystemdSystem {
+		fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+	} else {
+		fmt.Fprintf(&b, "WantedBy=default.target\n")
+	}
+	return b.Bytes(), nil
+}
+
+// systemdEscape escapes the specifier and variable characters % and $
+func systemdEscape(s string) string {
+	return strings.NewReplacer("%", "%%", "$", "$$").Replace(s)
+}
+
+// systemdQuote returns s as a double quoted systemd unit value
+func systemdQuote(s string) string {
+	return `"` + systemdEscape(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)) + `"`
+}
+
+// Systemctl runs systemctl with args on host h for units in scope and
+// returns the output
+func Systemctl(h *host.Host, scope string, args ...string) (out []byte, err error) {
+	if scope == SystemdUser {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err = h.Run("systemctl", args...)
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			err = fmt.Errorf("systemctl %s: %s", strings.Join(args, " "), msg)
+		}
+	}
+	return
+}
+
+func systemctl(c geneos.Instance, args ...string) (out []byte, err error) {
+	return Systemctl(c.Host(), c.V().GetString("systemd"), args...)
+}
+
+// startSystemd starts c through systemd and returns the main PID of the
+// service
+func startSystemd(c geneos.Instance) (pid int, err error) {
+	unit := SystemdUnitName(c)
+	if _, err = systemctl(c, "start", unit); err != nil {
+		return
+	}
+	out, err := systemctl(c, "show", "--property", "MainPID", "--value", unit)
+	if err == nil {
+		if pid, err = strconv.Atoi(strings.TrimSpace(string(out))); err == nil && pid != 0 {
+			writePIDFile(c, pid)
+			return
+		}
+	}
+	// fall back to looking for the process
+	time.Sleep(250 * time.Millisecond)
+	if pid, err = scanPID(c); err != nil {
+		err = fmt.Errorf("%s started but no process found", unit)
+		return
+	}
+	writePIDFile(c, pid)
+	return
+}
+
+// stopSystemd stops c through systemd, first killing the service if
+// force is set
+func stopSystemd(c geneos.Instance, force bool) (result Result) {
+	result = NewResult(c, "stop")
+	if _, err := GetPID(c); err == os.ErrProcessDone {
+		return result.Set(Unchanged, "")
+	}
+	unit := SystemdUnitName(c)
+	if force {
+		if _, err := systemctl(c, "kill", "--signal", "SIGKILL", unit); err != nil {
+			return result.Fail(err)
+		}
+	}
+	if _, err := systemctl(c, "stop", unit); err != nil {
+		return result.Fail(err)
+	}
+	if _, err := GetPID(c); err != os.ErrProcessDone {
+		return result.Fail(fmt.Errorf("still running after systemctl stop"))
+	}
+	if force {
+		return result.Set(OK, "killed")
+	}
+	return result.Set(OK, "stopped")
+}
@@ -20,13 +20,26 @@ import (
 //
 // skip if certificate exists (no expiry check)
 func CreateCert(c geneos.Instance) (err error) {
-	tlsDir := filepath.Join(host.Geneos(), "tls")
-
 	// skip if we can load an existing certificate
 	if _, err = ReadCert(c); err == nil {
 		return
 	}
 
+	expires, err := NewCert(c)
+	if err != nil {
+		return
+	}
+	if !host.DryRun {
+		log.Printf("certificate created for %s (expires %s)", c, expires)
+	}
+	return
+}
+
+// create a new certificate and private key for an instance, replacing
+// any that exist, and return the expiry time of the certificate
+func NewCert(c geneos.Instance) (expires time.Time, err error) {
+	tlsDir := filepath.Join(host.Geneos(), "tls")
+
 	hostname, _ := os.Hostname()
 	if c.Host() != host.LOCAL {
 		hostname = c.Host().GetString("hostname")
@@ -36,7 +49,7 @@ func CreateCert(c geneos.Instance) (err error) {
 	if err != nil {
 		return
 	}
-	expires := time.Now().AddDate(1, 0, 0)
+	expires = time.Now().AddDate(1, 0, 0)
 	template := x509.Certificate{
 		SerialNumber: serial,
 		Subject: pkix.Name{
@@ -69,12 +82,7 @@ func CreateCert(c geneos.Instance) (err error) {
 		return
 	}
 
-	if err = WriteKey(c, key); err != nil {
-		return
-	}
-
-	log.Printf("certificate created for %s (expires %s)", c, expires)
-
+	err = WriteKey(c, key)
 	return
 }
 
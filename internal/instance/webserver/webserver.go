@@ -48,9 +48,10 @@ var Webserver = geneos.Component{
 		"websxmx =1024m",
 	},
 	GlobalSettings: map[string]string{
-		"WebserverPortRange": "8080,8100-",
-		"WebserverCleanList": "*.old",
-		"WebserverPurgeList": "logs/*.log:webserver.txt",
+		"WebserverPortRange":   "8080,8100-",
+		"WebserverCleanList":   "*.old",
+		"WebserverPurgeList":   "logs/*.log:webserver.txt",
+		"WebserverStopTimeout": "30s",
 	},
 	Directories: []string{
 		"packages/webserver",
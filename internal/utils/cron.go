@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron schedule, in the five field format of crontab(5)
+type Cron struct {
+	minute, hour, dom, month, dow uint64 // bit sets of the allowed values
+	domStar, dowStar              bool   // field was "*", see Matches
+}
+
+var cronAliases = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonths = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var cronDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseCron parses spec, which is either five fields - minute, hour,
+// day of month, month and day of week - or one of the aliases @yearly,
+// @monthly, @weekly, @daily, @midnight or @hourly. Each field is a
+// comma separated list of "*", values and ranges, each optionally with
+// a "/STEP". Months and days of the week may be given as three letter
+// names and Sunday is either 0 or 7.
+func ParseCron(spec string) (c *Cron, err error) {
+	if alias, ok := cronAliases[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron schedule %q must have five fields", spec)
+	}
+	c = &Cron{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	if c.minute, err = cronField(fields[0], 0, 59, nil); err != nil {
+		return
+	}
+	if c.hour, err = cronField(fields[1], 0, 23, nil); err != nil {
+		return
+	}
+	if c.dom, err = cronField(fields[2], 1, 31, nil); err != nil {
+		return
+	}
+	if c.month, err = cronField(fields[3], 1, 12, cronMonths); err != nil {
+		return
+	}
+	if c.dow, err = cronField(fields[4], 0, 7, cronDays); err != nil {
+		return
+	}
+	// 7 is also Sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return
+}
+
+// cronField returns the bit set of the values in field, which must be
+// between min and max. names, if given, are the names of the values
+// from min.
+func cronField(field string, min, max int, names []string) (bits uint64, err error) {
+	value := func(s string) (int, error) {
+		for i, n := range names {
+			if strings.EqualFold(s, n) {
+				return min + i, nil
+			}
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v < min || v > max {
+			return 0, fmt.Errorf("invalid cron value %q, must be between %d and %d", s, min, max)
+		}
+		return v, nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid cron step in %q", part)
+			}
+			part = part[:i]
+		}
+		from, to := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			r := strings.SplitN(part, "-", 2)
+			if from, err = value(r[0]); err != nil {
+				return
+			}
+			if to, err = value(r[1]); err != nil {
+				return
+			}
+			if from > to {
+				return 0, fmt.Errorf("invalid cron range %q", part)
+			}
+		default:
+			if from, err = value(part); err != nil {
+				return
+			}
+			// a single value with a step, e.g. "5/15", runs to max
+			if step == 1 {
+				to = from
+			}
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+// Matches returns true if the minute containing t is in the schedule
+func (c *Cron) Matches(t time.Time) bool {
+	return c.minute&(1<<uint(t.Minute())) != 0 &&
+		c.hour&(1<<uint(t.Hour())) != 0 &&
+		c.month&(1<<uint(t.Month())) != 0 &&
+		c.dayMatches(t)
+}
+
+// Next returns the start of the first minute after t in the schedule,
+// or the zero time if there is none in the next five years, e.g. for
+// "0 0 31 2 *"
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(5, 0, 0); t.Before(end); {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches returns true if the day of t is in the schedule. As in
+// cron, if both the day of month and day of week are restricted then
+// either may match.
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
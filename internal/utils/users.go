@@ -129,8 +129,13 @@ func CanControl(username string) bool {
 	return username == uc.Username
 }
 
-func ReadPasswordPrompt() string {
-	fmt.Printf("Password: ")
+// ReadPasswordPrompt reads a password from the terminal without echoing
+// it. The prompt defaults to "Password: ".
+func ReadPasswordPrompt(prompt ...string) string {
+	if len(prompt) == 0 {
+		prompt = []string{"Password: "}
+	}
+	fmt.Print(strings.Join(prompt, " "))
 	pw, err := term.ReadPassword(int(syscall.Stdin))
 	if err != nil {
 		log.Fatalln("Error getting password:", err)